@@ -111,7 +111,21 @@ func NewHandler(app *app.App, staticContent fs.FS) *Handler {
 	r.HandleFunc("/api/mailbox/{box}/{mid}", h.messageDeleteHandler).Methods("DELETE")
 	r.HandleFunc("/api/mailbox/{box}/{mid}/{attachment}", h.attachmentHandler).Methods("GET")
 	r.HandleFunc("/api/mailbox/{box}/{mid}/read", h.readHandler).Methods("POST")
+	r.HandleFunc("/api/mailbox/{box}/{mid}/move", h.moveMessageHandler).Methods("POST")
+	r.HandleFunc("/api/mailbox/out/{mid}/outbox", h.outboxMetaHandler).Methods("PUT")
+	r.HandleFunc("/api/mailbox/trash/{mid}/restore", h.restoreMessageHandler).Methods("POST")
+	r.HandleFunc("/api/mailbox/trash", h.emptyTrashHandler).Methods("DELETE")
+	r.HandleFunc("/api/mailbox/{box}/bulk", h.bulkHandler).Methods("POST")
 	r.HandleFunc("/api/mailbox/{box}", h.postMessageHandler).Methods("POST")
+	r.HandleFunc("/api/folders", h.foldersHandler).Methods("GET", "POST")
+	r.HandleFunc("/api/search", h.searchHandler).Methods("GET")
+	r.HandleFunc("/api/threads", h.threadsHandler).Methods("GET")
+	r.HandleFunc("/api/threads/{id}", h.threadHandler).Methods("GET")
+	r.HandleFunc("/api/folders/{name}", h.folderHandler).Methods("PUT", "DELETE")
+	r.HandleFunc("/api/contacts", h.contactsHandler).Methods("GET", "POST")
+	r.HandleFunc("/api/contacts/groups", h.contactGroupsHandler).Methods("GET")
+	r.HandleFunc("/api/contacts/groups/{name}", h.contactGroupHandler).Methods("GET", "PUT", "DELETE")
+	r.HandleFunc("/api/contacts/{address}", h.contactHandler).Methods("GET", "PUT", "DELETE")
 
 	r.HandleFunc("/api/posreport", h.postPositionHandler).Methods("POST")
 	r.HandleFunc("/api/status", h.statusHandler).Methods("GET")
@@ -119,6 +133,10 @@ func NewHandler(app *app.App, staticContent fs.FS) *Handler {
 	r.HandleFunc("/api/coords_to_locator", h.coordsToLocatorHandler).Methods("POST")
 	r.HandleFunc("/api/qsy", h.qsyHandler).Methods("POST")
 	r.HandleFunc("/api/rmslist", h.rmslistHandler).Methods("GET")
+	r.HandleFunc("/api/eventlog", h.eventLogHandler).Methods("GET")
+	r.HandleFunc("/api/schedule", h.scheduleHandler).Methods("GET", "POST")
+	r.HandleFunc("/api/schedule/{id}", h.scheduleJobHandler).Methods("GET", "DELETE")
+	r.HandleFunc("/api/schedule/{id}/trigger", h.scheduleTriggerHandler).Methods("POST")
 
 	r.HandleFunc("/api/config", h.configHandler).Methods("GET", "PUT")
 	r.HandleFunc("/api/config/connect_aliases", h.connectAliasesHandler).Methods("GET")
@@ -312,6 +330,7 @@ func (h Handler) bandwidthsHandler(w http.ResponseWriter, req *http.Request) {
 func (h Handler) rmslistHandler(w http.ResponseWriter, req *http.Request) {
 	var (
 		forceDownload, _ = strconv.ParseBool(req.FormValue("force-download"))
+		sortHistory, _   = strconv.ParseBool(req.FormValue("sort-history"))
 		band             = req.FormValue("band")
 		mode             = strings.ToLower(req.FormValue("mode"))
 		prefix           = strings.ToUpper(req.FormValue("prefix"))
@@ -337,14 +356,16 @@ func (h Handler) rmslistHandler(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Sort by predictions if we have more than 1/3 entries with predictions,
-	// otherwise sort by distance.
+	// otherwise sort by distance (unless sorting by connection history is requested).
 	nPredictions := 0
 	for _, rms := range list {
 		if rms.Prediction != nil {
 			nPredictions++
 		}
 	}
-	if nPredictions > len(list)/3 {
+	if sortHistory {
+		sort.Sort(sort.Reverse(app.ByHistory(list)))
+	} else if nPredictions > len(list)/3 {
 		sort.Sort(sort.Reverse(app.ByLinkQuality(list)))
 	} else {
 		sort.Sort(app.ByDist(list))
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/la5nta/pat/app"
+)
+
+const bulkExport = "export"
+
+func (h Handler) bulkHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Action string   `json:"action"`
+		MIDs   []string `json:"mids"`
+		Target string   `json:"target"` // Destination folder of the move action.
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	box := mux.Vars(r)["box"]
+
+	var results []app.BulkResult
+	var err error
+	if req.Action == bulkExport {
+		var buf bytes.Buffer
+		if results, err = h.ExportMessages(&buf, box, req.MIDs); err == nil {
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", `attachment; filename="messages.zip"`)
+			_, _ = buf.WriteTo(w)
+			return
+		}
+	} else {
+		results, err = h.BulkUpdate(box, req.Action, req.MIDs, req.Target)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case errors.Is(err, app.ErrBulkFailed):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case err != nil && results == nil:
+		http.Error(w, err.Error(), folderErrorStatus(err))
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	_ = json.NewEncoder(w).Encode(struct {
+		Results []app.BulkResult `json:"results"`
+	}{results})
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/la5nta/pat/app"
+)
+
+func (h Handler) contactsHandler(w http.ResponseWriter, r *http.Request) {
+	book := h.AddressBook()
+	switch r.Method {
+	case http.MethodGet:
+		contacts, err := book.Contacts(r.URL.Query().Get("q"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if contacts == nil {
+			contacts = []app.Contact{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(contacts)
+	case http.MethodPost:
+		var c app.Contact
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c, err := book.AddContact(c)
+		if err != nil {
+			http.Error(w, err.Error(), contactErrorStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(c)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h Handler) contactHandler(w http.ResponseWriter, r *http.Request) {
+	book, addr := h.AddressBook(), mux.Vars(r)["address"]
+	switch r.Method {
+	case http.MethodGet:
+		c, err := book.Contact(addr)
+		if err != nil {
+			http.Error(w, err.Error(), contactErrorStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c)
+	case http.MethodPut:
+		var c app.Contact
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if c.Address == "" {
+			c.Address = addr
+		}
+		c, err := book.UpdateContact(addr, c)
+		if err != nil {
+			http.Error(w, err.Error(), contactErrorStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c)
+	case http.MethodDelete:
+		if err := book.DeleteContact(addr); err != nil {
+			http.Error(w, err.Error(), contactErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h Handler) contactGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.AddressBook().Groups()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if groups == nil {
+		groups = []app.ContactGroup{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(groups)
+}
+
+func (h Handler) contactGroupHandler(w http.ResponseWriter, r *http.Request) {
+	book, name := h.AddressBook(), mux.Vars(r)["name"]
+	switch r.Method {
+	case http.MethodGet:
+		g, err := book.Group(name)
+		if err != nil {
+			http.Error(w, err.Error(), contactErrorStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(g)
+	case http.MethodPut:
+		var g app.ContactGroup
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		g.Name = name
+		if err := book.PutGroup(g); err != nil {
+			http.Error(w, err.Error(), contactErrorStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(g)
+	case http.MethodDelete:
+		if err := book.DeleteGroup(name); err != nil {
+			http.Error(w, err.Error(), contactErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func contactErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, app.ErrContactNotFound), errors.Is(err, app.ErrGroupNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, app.ErrContactExists):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
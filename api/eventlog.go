@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/la5nta/pat/app"
+)
+
+func (h Handler) eventLogHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := app.EventFilter{
+		What:      q.Get("type"),
+		Target:    q.Get("target"),
+		Transport: q.Get("transport"),
+	}
+	now := time.Now()
+	for key, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := q.Get(key); v != "" {
+			t, err := app.ParseEventTime(v, now)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			*dst = t
+		}
+	}
+	if v := q.Get("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid success value", http.StatusBadRequest)
+			return
+		}
+		filter.Success = &success
+	}
+
+	events, err := h.Events(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch q.Get("format") {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="eventlog.csv"`)
+		_ = app.WriteEventsCSV(w, events)
+	case "json", "":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(events)
+	default:
+		http.Error(w, "unsupported format", http.StatusBadRequest)
+	}
+}
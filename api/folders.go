@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/la5nta/pat/app"
+)
+
+func (h Handler) foldersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		folders, err := h.Folders()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(folders)
+	case http.MethodPost:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.CreateFolder(req.Name); err != nil {
+			http.Error(w, err.Error(), folderErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h Handler) folderHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.RenameFolder(name, req.Name); err != nil {
+			http.Error(w, err.Error(), folderErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := h.DeleteFolder(name); err != nil {
+			http.Error(w, err.Error(), folderErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h Handler) moveMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Folder string `json:"folder"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	box, mid := mux.Vars(r)["box"], mux.Vars(r)["mid"]
+	if err := h.MoveMessage(mid, box, req.Folder); err != nil {
+		http.Error(w, err.Error(), folderErrorStatus(err))
+		return
+	}
+	_ = json.NewEncoder(w).Encode("OK")
+}
+
+func (h Handler) outboxMetaHandler(w http.ResponseWriter, r *http.Request) {
+	var req app.OutboxMeta
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	meta, err := h.UpdateOutboxMeta(mux.Vars(r)["mid"], func(meta *app.OutboxMeta) { *meta = req })
+	if err != nil {
+		http.Error(w, err.Error(), folderErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(app.OutboxStatus{OutboxMeta: meta, State: meta.State(time.Now())})
+}
+
+func folderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, app.ErrFolderNotFound), os.IsNotExist(err):
+		return http.StatusNotFound
+	case errors.Is(err, app.ErrFolderExists), errors.Is(err, app.ErrFolderNotEmpty):
+		return http.StatusConflict
+	case errors.Is(err, app.ErrFolderBuiltin):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -13,14 +13,12 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/la5nta/pat/app"
 	"github.com/la5nta/pat/internal/debug"
-	"github.com/la5nta/pat/internal/directories"
 	"github.com/la5nta/wl2k-go/fbb"
 	"github.com/la5nta/wl2k-go/mailbox"
 
@@ -31,35 +29,81 @@ import (
 func (h Handler) mailboxHandler(w http.ResponseWriter, r *http.Request) {
 	box := mux.Vars(r)["box"]
 
-	var messages []*fbb.Message
-	var err error
-
-	switch box {
-	case "in":
-		messages, err = h.Mailbox().Inbox()
-	case "out":
-		messages, err = h.Mailbox().Outbox()
-	case "sent":
-		messages, err = h.Mailbox().Sent()
-	case "archive":
-		messages, err = h.Mailbox().Archive()
-	default:
-		http.NotFound(w, r)
+	q, err := parseMailboxQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err != nil {
+	messages, total, err := h.IndexedMessages(box, q)
+	if errors.Is(err, app.ErrFolderNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		log.Println(err)
+		return
 	}
 
-	sort.Sort(sort.Reverse(fbb.ByDate(messages)))
+	if messages == nil {
+		messages = []app.IndexedMessage{}
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	_ = json.NewEncoder(w).Encode(messages)
+}
 
-	jsonSlice := make([]JSONMessage, len(messages))
-	for i, msg := range messages {
-		jsonSlice[i] = JSONMessage{Message: msg}
+// parseMailboxQuery parses the sort, order, offset, limit, unread, from, to, subject, since and until query parameters.
+func parseMailboxQuery(v url.Values) (app.MailboxQuery, error) {
+	q := app.MailboxQuery{
+		Sort:    v.Get("sort"),
+		From:    v.Get("from"),
+		To:      v.Get("to"),
+		Subject: v.Get("subject"),
+	}
+	switch q.Sort {
+	case "", "date", "from", "to", "subject", "size", "priority":
+	default:
+		return q, fmt.Errorf("invalid sort key '%s'", q.Sort)
+	}
+	switch order := v.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		q.Asc = true
+	default:
+		return q, fmt.Errorf("invalid order '%s'", order)
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"offset", &q.Offset}, {"limit", &q.Limit}} {
+		if str := v.Get(p.name); str != "" {
+			n, err := strconv.Atoi(str)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("invalid %s '%s'", p.name, str)
+			}
+			*p.dst = n
+		}
+	}
+	if str := v.Get("unread"); str != "" {
+		unread, err := strconv.ParseBool(str)
+		if err != nil {
+			return q, fmt.Errorf("invalid unread value '%s'", str)
+		}
+		q.Unread = &unread
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		if str := v.Get(p.name); str != "" {
+			t, err := time.Parse(time.RFC3339, str)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s '%s': %w", p.name, str, err)
+			}
+			*p.dst = t
+		}
 	}
-	_ = json.NewEncoder(w).Encode(jsonSlice)
+	return q, nil
 }
 
 type JSONMessage struct {
@@ -103,22 +147,41 @@ func (m JSONMessage) MarshalJSON() ([]byte, error) {
 func (h Handler) messageDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	box, mid := mux.Vars(r)["box"], mux.Vars(r)["mid"]
 
-	file := filepath.Clean(filepath.Join(h.Mailbox().MBoxPath, box, mid+mailbox.Ext))
-	if !directories.IsInPath(h.Mailbox().MBoxPath, file) {
-		log.Println("Malicious source path in move:", file)
-		http.Error(w, "malicious source path", http.StatusBadRequest)
+	err := h.DeleteMessage(mid, box)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err := os.Remove(file)
+	_ = json.NewEncoder(w).Encode("OK")
+}
+
+func (h Handler) restoreMessageHandler(w http.ResponseWriter, r *http.Request) {
+	folder, err := h.RestoreMessage(mux.Vars(r)["mid"])
 	if os.IsNotExist(err) {
 		http.NotFound(w, r)
 		return
 	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	_ = json.NewEncoder(w).Encode(struct {
+		Folder string `json:"folder"`
+	}{folder})
+}
 
-	_ = json.NewEncoder(w).Encode("OK")
+func (h Handler) emptyTrashHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := h.EmptyTrash()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(struct {
+		Deleted int `json:"deleted"`
+	}{n})
 }
 
 func (h Handler) messageHandler(w http.ResponseWriter, r *http.Request) {
@@ -242,19 +305,8 @@ func (h Handler) postMessageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	srcPath, _ = url.PathUnescape(strings.TrimPrefix(srcPath, "/api/mailbox/"))
-	srcPath = filepath.Join(h.Mailbox().MBoxPath, srcPath+mailbox.Ext)
-
-	// Check that we don't escape our mailbox path
-	srcPath = filepath.Clean(srcPath)
-	if !directories.IsInPath(h.Mailbox().MBoxPath, srcPath) {
-		log.Println("Malicious source path in move:", srcPath)
-		http.Error(w, "malicious source path", http.StatusBadRequest)
-		return
-	}
-
-	targetPath := filepath.Join(h.Mailbox().MBoxPath, box, filepath.Base(srcPath))
-
-	if err := os.Rename(srcPath, targetPath); err != nil {
+	srcBox, mid := path.Split(path.Clean(srcPath))
+	if err := h.MoveMessage(mid, path.Clean(srcBox), box); err != nil {
 		log.Println("Could not move message:", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	} else {
@@ -308,11 +360,11 @@ func (h Handler) postOutboundMessageHandler(w http.ResponseWriter, r *http.Reque
 	// Other fields
 	if v := r.Form["to"]; len(v) == 1 {
 		addrs := strings.FieldsFunc(v[0], app.SplitFunc)
-		msg.AddTo(addrs...)
+		msg.AddTo(h.ExpandRecipients(addrs)...)
 	}
 	if v := r.Form["cc"]; len(v) == 1 {
 		addrs := strings.FieldsFunc(v[0], app.SplitFunc)
-		msg.AddCc(addrs...)
+		msg.AddCc(h.ExpandRecipients(addrs)...)
 	}
 	if v := r.Form["subject"]; len(v) == 1 {
 		msg.SetSubject(v[0])
@@ -323,6 +375,41 @@ func (h Handler) postOutboundMessageHandler(w http.ResponseWriter, r *http.Reque
 	if v := r.Form["p2ponly"]; len(v) == 1 && v[0] != "" {
 		msg.Header.Set("X-P2POnly", "true")
 	}
+	if v := r.Form["in_reply_to"]; len(v) == 1 && v[0] != "" {
+		// Given as box/mid by the web gui
+		original, err := mailbox.OpenMessage(filepath.Join(h.Mailbox().MBoxPath, filepath.Clean("/"+v[0])+mailbox.Ext))
+		if err != nil {
+			debug.Printf("Unable to open in-reply-to message (%q): %v", v[0], err)
+		} else {
+			app.SetInReplyTo(msg, original)
+		}
+	}
+	var meta app.OutboxMeta
+	if v := r.Form["hold"]; len(v) == 1 && v[0] != "" {
+		hold, err := strconv.ParseBool(v[0])
+		if err != nil {
+			http.Error(w, "invalid hold value", http.StatusBadRequest)
+			return
+		}
+		meta.Hold = hold
+	}
+	if v := r.Form["priority"]; len(v) == 1 && v[0] != "" {
+		n, err := strconv.Atoi(v[0])
+		if err != nil {
+			http.Error(w, "invalid priority value", http.StatusBadRequest)
+			return
+		}
+		meta.Priority = n
+	}
+	if v := r.Form["send_after"]; len(v) == 1 && v[0] != "" {
+		t, err := time.Parse(time.RFC3339, v[0])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		meta.SendAfter = t
+	}
+	app.SetOutboxMeta(msg, meta)
 	if v := r.Form["date"]; len(v) == 1 {
 		t, err := time.Parse(time.RFC3339, v[0])
 		if err != nil {
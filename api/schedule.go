@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/la5nta/pat/app"
+)
+
+func (h Handler) scheduleHandler(w http.ResponseWriter, r *http.Request) {
+	scheduler := h.Scheduler()
+	if scheduler == nil {
+		http.Error(w, "scheduler not running", http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(scheduler.Jobs())
+	case http.MethodPost:
+		var req struct {
+			Expr    string `json:"expr"`
+			Command string `json:"command"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		job, err := scheduler.Add(req.Expr, req.Command)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(job)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h Handler) scheduleJobHandler(w http.ResponseWriter, r *http.Request) {
+	scheduler := h.Scheduler()
+	if scheduler == nil {
+		http.Error(w, "scheduler not running", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		job, ok := scheduler.Job(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(job)
+	case http.MethodDelete:
+		if err := scheduler.Remove(id); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h Handler) scheduleTriggerHandler(w http.ResponseWriter, r *http.Request) {
+	scheduler := h.Scheduler()
+	if scheduler == nil {
+		http.Error(w, "scheduler not running", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+	switch err := scheduler.Trigger(id); {
+	case errors.Is(err, app.ErrJobNotFound):
+		http.NotFound(w, r)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/la5nta/pat/app"
+)
+
+func (h Handler) searchHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "missing query parameter 'q'", http.StatusBadRequest)
+		return
+	}
+	results, err := h.Search(query, r.URL.Query()["folder"]...)
+	if err != nil {
+		http.Error(w, err.Error(), folderErrorStatus(err))
+		return
+	}
+	if results == nil {
+		results = []app.SearchResult{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
+}
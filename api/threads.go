@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (h Handler) threadsHandler(w http.ResponseWriter, r *http.Request) {
+	threads, err := h.Threads()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range threads {
+		threads[i].Messages = nil // See /api/threads/{id}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(threads)
+}
+
+func (h Handler) threadHandler(w http.ResponseWriter, r *http.Request) {
+	thread, ok, err := h.Thread(mux.Vars(r)["id"])
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case !ok:
+		http.NotFound(w, r)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(thread)
+	}
+}
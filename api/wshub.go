@@ -14,6 +14,7 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -126,14 +127,33 @@ func (w *WSHub) WatchMBox(ctx context.Context, mbox *mailbox.DirHandler) {
 	}
 	defer fsWatcher.Close()
 
-	// Add all directories in the mailbox to the watcher
-	for _, dir := range []string{mailbox.DIR_INBOX, mailbox.DIR_OUTBOX, mailbox.DIR_SENT, mailbox.DIR_ARCHIVE} {
-		p := path.Join(mbox.MBoxPath, dir)
+	// Add the mailbox root (for folder changes) and all folders in the mailbox to the watcher
+	watch := func(p string) {
 		debug.Printf("Adding '%s' to fs watcher", p)
 		if err := fsWatcher.Add(p); err != nil {
 			log.Printf("Unable to add path '%s' to fs watcher: %v", p, err)
 		}
 	}
+	watch(mbox.MBoxPath)
+	entries, err := os.ReadDir(mbox.MBoxPath)
+	if err != nil {
+		log.Printf("Unable to read mailbox directory: %v", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			watch(path.Join(mbox.MBoxPath, e.Name()))
+		}
+	}
+
+	// handle updates the mailbox index and starts watching new folders
+	handle := func(e fsnotify.Event) {
+		w.MailboxIndex().Update(e.Name)
+		if e.Op.Has(fsnotify.Create) && path.Dir(e.Name) == path.Clean(mbox.MBoxPath) {
+			if fi, err := os.Stat(e.Name); err == nil && fi.IsDir() {
+				watch(e.Name) // New folder
+			}
+		}
+	}
 
 	// Listen for filesystem events and broadcast updates to all clients
 	for {
@@ -141,11 +161,12 @@ func (w *WSHub) WatchMBox(ctx context.Context, mbox *mailbox.DirHandler) {
 		case <-ctx.Done():
 			return
 		case e := <-fsWatcher.Events:
-			if e.Op == fsnotify.Chmod {
+			if ignoreMBoxEvent(e) {
 				continue
 			}
+			handle(e)
 			// Make sure we don't send many of these events over a short period.
-			drainUntilSilence(fsWatcher, 100*time.Millisecond)
+			drainUntilSilence(fsWatcher, 100*time.Millisecond, handle)
 			w.WriteJSON(struct {
 				UpdateMailbox bool
 			}{true})
@@ -155,6 +176,11 @@ func (w *WSHub) WatchMBox(ctx context.Context, mbox *mailbox.DirHandler) {
 	}
 }
 
+// ignoreMBoxEvent returns true for permission changes and changes to hidden files (e.g. the mailbox index).
+func ignoreMBoxEvent(e fsnotify.Event) bool {
+	return e.Op == fsnotify.Chmod || strings.HasPrefix(path.Base(e.Name), ".")
+}
+
 // Handle adds a new websocket to the hub
 //
 // It will block until the client either stops responding or closes the connection.
@@ -224,12 +250,15 @@ func (w *WSHub) Handle(conn *websocket.Conn) {
 }
 
 // drainEvents reads from w.Events and blocks until the channel has been silent for at least 50 ms.
-func drainUntilSilence(w *fsnotify.Watcher, silenceDur time.Duration) {
+func drainUntilSilence(w *fsnotify.Watcher, silenceDur time.Duration, handle func(fsnotify.Event)) {
 	timer := time.NewTimer(silenceDur)
 	defer timer.Stop()
 	for {
 		select {
-		case <-w.Events:
+		case e := <-w.Events:
+			if !ignoreMBoxEvent(e) {
+				handle(e)
+			}
 			if !timer.Stop() {
 				<-timer.C
 			}
@@ -255,6 +284,11 @@ func tailFile(path string) (<-chan []byte, chan<- struct{}, error) {
 			data, _, err := rd.ReadLine()
 			if errors.Is(err, io.EOF) {
 				time.Sleep(time.Millisecond * 100)
+				// Follow the file if it has been rotated.
+				if rotated, ok := reopenIfRotated(file, path); ok {
+					file.Close()
+					file, rd = rotated, bufio.NewReader(rotated)
+				}
 				continue
 			}
 
@@ -270,6 +304,22 @@ func tailFile(path string) (<-chan []byte, chan<- struct{}, error) {
 	return lines, done, nil
 }
 
+// reopenIfRotated opens path if it no longer refers to the given file.
+func reopenIfRotated(file *os.File, path string) (*os.File, bool) {
+	current, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if info, err := file.Stat(); err == nil && os.SameFile(info, current) {
+		return nil, false
+	}
+	rotated, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	return rotated, true
+}
+
 func (w *WSHub) handleWSMessage(v map[string]json.RawMessage) {
 	raw, ok := v["prompt_response"]
 	if !ok {
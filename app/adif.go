@@ -0,0 +1,171 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/la5nta/pat/internal/adif"
+	"github.com/la5nta/pat/internal/buildinfo"
+)
+
+// adifSessions converts exchange events to ADIF QSO records.
+//
+// Exchange events are paired with the preceding connect event to determine
+// the frequency and transport of the session.
+type adifSessions struct{ lastConnect *Event }
+
+func (s *adifSessions) next(e Event) (adif.Record, bool) {
+	switch e.What {
+	case EventConnect:
+		s.lastConnect = nil
+		if e.Success {
+			s.lastConnect = &e
+		}
+		return nil, false
+	case EventExchange:
+		connect := s.lastConnect
+		s.lastConnect = nil
+		if !e.Success {
+			return nil, false // A failed session is not a QSO
+		}
+		return adifRecord(connect, e)
+	default:
+		return nil, false
+	}
+}
+
+// ADIFRecords returns ADIF QSO records of the radio sessions (exchange events) matching the given filter.
+//
+// Failed sessions and sessions over non-radio transports (e.g. telnet) are omitted.
+func ADIFRecords(events []Event, filter EventFilter) []adif.Record {
+	var sessions adifSessions
+	var records []adif.Record
+	for _, e := range events {
+		r, ok := sessions.next(e)
+		if !ok || !filter.Match(e) {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// WriteADIF writes the given records as an ADIF file (including header).
+func WriteADIF(w io.Writer, records []adif.Record) error {
+	if err := adif.WriteHeader(w, buildinfo.AppName, buildinfo.Version); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := adif.WriteRecord(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func adifRecord(connect *Event, exchange Event) (adif.Record, bool) {
+	var transport string
+	var freq Frequency
+	if connect != nil {
+		transport, freq = connect.Transport(), connect.Freq
+		if url := connect.ConnectURL(); url != nil && freq == 0 {
+			if f, err := strconv.ParseFloat(url.Params.Get("freq"), 64); err == nil {
+				freq = Frequency(math.Round(f * 1e3))
+			}
+		}
+	}
+	if transport == "" {
+		transport = exchange.Network
+	}
+	mode, submode, ok := adifMode(transport, freq)
+	if !ok {
+		return nil, false
+	}
+
+	var r adif.Record
+	r.Set("CALL", baseCallsign(exchange.TargetCall))
+	r.Set("STATION_CALLSIGN", baseCallsign(exchange.MyCall))
+	r.SetTime("QSO_DATE", "TIME_ON", time.Unix(exchange.Start, 0))
+	r.SetTime("QSO_DATE_OFF", "TIME_OFF", time.Unix(exchange.End, 0))
+	if freq > 0 {
+		r.Set("FREQ", strconv.FormatFloat(freq.MHz(), 'f', 6, 64))
+		r.Set("BAND", freq.Band())
+	}
+	r.Set("MODE", mode)
+	r.Set("SUBMODE", submode)
+	r.Set("MY_GRIDSQUARE", exchange.LocalLocator)
+	r.Set("COMMENT", fmt.Sprintf("Winlink session (%d sent, %d received)", len(exchange.Sent), len(exchange.Received)))
+	return r, true
+}
+
+// adifMode maps a transport to ADIF mode and submode.
+//
+// ok is false for non-radio transports (e.g. telnet).
+func adifMode(transport string, freq Frequency) (mode, submode string, ok bool) {
+	transport = strings.ToLower(transport)
+	switch {
+	case transport == MethodArdop:
+		return "ARDOP", "", true
+	case transport == MethodPactor:
+		return "PAC", "", true
+	case transport == MethodVaraHF:
+		return "DYNAMIC", "VARA HF", true
+	case transport == MethodVaraFM:
+		return "DYNAMIC", "VARA FM 1200", true
+	case transport == "vara" && freq >= 30e6:
+		return "DYNAMIC", "VARA FM 1200", true
+	case transport == "vara":
+		return "DYNAMIC", "VARA HF", true
+	case strings.HasPrefix(transport, MethodAX25), transport == "ax.25":
+		return "PKT", "", true
+	default:
+		return "", "", false
+	}
+}
+
+// baseCallsign strips any SSID from the given callsign.
+func baseCallsign(call string) string {
+	call, _, _ = strings.Cut(strings.ToUpper(call), "-")
+	return call
+}
+
+// adifLogger appends radio sessions to an ADIF file as they are written to the event log.
+func adifLogger(path string) func(Event) {
+	var sessions adifSessions
+	return func(e Event) {
+		r, ok := sessions.next(e)
+		if !ok {
+			return
+		}
+		if err := appendADIF(path, r); err != nil {
+			log.Printf("Unable to append session to ADIF log: %v", err)
+		}
+	}
+}
+
+func appendADIF(path string, r adif.Record) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		if err := adif.WriteHeader(f, buildinfo.AppName, buildinfo.Version); err != nil {
+			return err
+		}
+	}
+	if err := adif.WriteRecord(f, r); err != nil {
+		return err
+	}
+	return f.Close()
+}
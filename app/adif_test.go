@@ -0,0 +1,45 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestADIFRecords(t *testing.T) {
+	const eventLog = `
+{"log_time":"2024-01-01T10:05:00Z","what":"connect","operation":"connect varahf:///LA1B?freq=7103.5","success":true,"remote_addr":"LA1B","network":"vara"}
+{"log_time":"2024-01-01T10:06:40Z","what":"exchange","mycall":"LA5NTA-1","targetcall":"LA1B-10","local_locator":"JP20qe","success":true,"remote_addr":"LA1B","network":"vara","sent":["ABC"],"start":1704103500,"end":1704103600}
+{"log_time":"2024-01-01T10:30:00Z","what":"connect","operation":"connect varahf:///LA1B?freq=7103.5","success":true,"remote_addr":"LA1B","network":"vara"}
+{"log_time":"2024-01-01T10:31:00Z","what":"exchange","mycall":"LA5NTA","targetcall":"LA1B-10","success":false,"error":"connection lost","remote_addr":"LA1B","network":"vara","start":1704105000,"end":1704105060}
+{"log_time":"2024-01-01T11:00:00Z","what":"connect","operation":"connect telnet","success":true,"network":"telnet"}
+{"log_time":"2024-01-01T11:01:00Z","what":"exchange","mycall":"LA5NTA","targetcall":"CMS","success":true,"start":1704106800,"end":1704106860}
+`
+	events, err := DecodeEvents(strings.NewReader(eventLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := ADIFRecords(events, EventFilter{})
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record (failed and telnet omitted), got %d", len(records))
+	}
+	r := records[0]
+	for name, expect := range map[string]string{
+		"CALL":             "LA1B",
+		"STATION_CALLSIGN": "LA5NTA",
+		"QSO_DATE":         "20240101",
+		"TIME_ON":          "100500",
+		"TIME_OFF":         "100640",
+		"FREQ":             "7.103500",
+		"BAND":             "40m",
+		"MODE":             "DYNAMIC",
+		"SUBMODE":          "VARA HF",
+		"MY_GRIDSQUARE":    "JP20qe",
+	} {
+		if got := r.Get(name); got != expect {
+			t.Errorf("%s: expected %q, got %q", name, expect, got)
+		}
+	}
+	if records := ADIFRecords(events, EventFilter{Transport: "varahf"}); len(records) != 1 {
+		t.Errorf("expected VARA HF session to match transport filter, got %d records", len(records))
+	}
+}
@@ -17,6 +17,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/harenber/ptc-go/v2/pactor"
@@ -26,6 +28,7 @@ import (
 	"github.com/la5nta/pat/internal/debug"
 	"github.com/la5nta/pat/internal/directories"
 	"github.com/la5nta/pat/internal/forms"
+	"github.com/la5nta/pat/internal/logrotate"
 	"github.com/la5nta/pat/internal/propagation"
 	"github.com/la5nta/wl2k-go/fbb"
 	"github.com/la5nta/wl2k-go/mailbox"
@@ -74,15 +77,21 @@ type App struct {
 	config   cfg.Config
 	OnReload func() error
 
-	mbox     *mailbox.DirHandler
-	formsMgr *forms.Manager
+	mbox      *mailbox.DirHandler
+	mboxIndex *MailboxIndex
+	formsMgr  *forms.Manager
+	contacts  *AddressBook
 
 	exchangeChan   chan ex        // The channel that the exchange loop is listening on
 	exchangeConn   net.Conn       // Pointer to the active session connection (exchange)
 	dialing        *transport.URL // The connect URL currently being dialed (if any)
 	dialCancelFunc func()         // Context cancellation function for aborting while dialing.
+	abortRequested atomic.Bool    // True if the user aborted the most recent connect attempt (stops connect chains).
 	listenHub      *ListenerHub
 
+	inboundMu      sync.Mutex
+	inboundActions []inboundAction // Inbound rule actions to run when the current session is over.
+
 	promptHub    *PromptHub
 	websocketHub WSHub
 
@@ -98,6 +107,7 @@ type App struct {
 	predictor propagation.Predictor
 
 	eventLog   *EventLogger
+	scheduler  *Scheduler
 	termWriter io.WriteCloser // termWriter writes to both stdout and the log file (web gui echoes log file)
 }
 
@@ -119,6 +129,8 @@ func New(opts Options) *App {
 
 func (a *App) Mailbox() *mailbox.DirHandler { return a.mbox }
 
+func (a *App) MailboxIndex() *MailboxIndex { return a.mboxIndex }
+
 func (a *App) FormsManager() *forms.Manager { return a.formsMgr }
 
 func (a *App) Config() cfg.Config { return a.config }
@@ -199,20 +211,40 @@ func (a *App) Run(ctx context.Context, cmd Command, args []string) {
 	}
 
 	// Initialize logger
-	f, err := os.Create(a.options.LogPath)
+	//
+	// The log files are shared with other pat processes (e.g. `pat compose` while `pat http` is
+	// running). Only long-lived processes rotate them, the others follow.
+	rotation := logRotateOptions(a.config.LogRotation)
+	rotation.NoRotate = !cmd.LongLived
+	f, err := logrotate.Open(a.options.LogPath, rotation)
 	if err != nil {
 		log.Fatalf("Unable to create log file at %s: %v", a.options.LogPath, err)
 	}
+	// Start a new log on each startup of a long-lived process, keeping the previous run's log as a generation.
+	if err := f.Rotate(); err != nil {
+		log.Fatalf("Unable to rotate log file at %s: %v", a.options.LogPath, err)
+	}
 	a.termWriter = struct {
 		io.Writer
 		io.Closer
 	}{io.MultiWriter(f, os.Stdout), f}
 	log.SetOutput(io.MultiWriter(f, os.Stderr)) // web gui echoes the log file
 
-	a.eventLog, err = NewEventLogger(a.options.EventLogPath)
+	a.eventLog, err = NewEventLogger(a.options.EventLogPath, rotation)
 	if err != nil {
 		log.Fatal("Unable to open event log file:", err)
 	}
+	if path := a.config.ADIFLogPath; path != "" {
+		a.eventLog.Subscribe(adifLogger(path))
+	}
+	a.eventLog.Subscribe(a.notifySessionFailed) // Notifications may be configured later (config reload).
+	if file := a.config.Posthook; file != "" {
+		if path, err := a.posthookPath(file); err != nil {
+			log.Printf("posthook invalid: %s", err)
+		} else {
+			a.eventLog.Subscribe(a.posthook(path, a.config.PosthookArgs))
+		}
+	}
 
 	// Read command line options from config if unset
 	if a.options.MyCall == "" && a.config.MyCall == "" {
@@ -278,6 +310,9 @@ func (a *App) Run(ctx context.Context, cmd Command, args []string) {
 	if err := a.mbox.Prepare(); err != nil {
 		log.Fatal(err)
 	}
+	a.mboxIndex = OpenMailboxIndex(a.mbox.MBoxPath)
+	a.contacts = OpenAddressBook(filepath.Join(filepath.Dir(a.options.ConfigPath), contactsFileName))
+	a.purgeTrash()
 
 	if cmd.MayConnect {
 		a.loadHamlibRigs(a.config.HamlibRigs)
@@ -306,6 +341,16 @@ func (a *App) Run(ctx context.Context, cmd Command, args []string) {
 		if a.config.GPSd.UpdateLocator {
 			go a.gpsdLocatorUpdater(ctx)
 		}
+		a.startScheduler(ctx)
+		if a.config.TrashPurgeDays > 0 {
+			go a.trashPurger(ctx)
+		}
+		if a.config.SMTP.Enabled {
+			go a.serveSMTP(ctx)
+		}
+		if a.config.IMAP.Enabled {
+			go a.serveIMAP(ctx)
+		}
 	}
 
 	// Start command execution
@@ -349,6 +394,16 @@ func (a *App) Heard() map[string][]Heard {
 	return heard
 }
 
+// WriteHeard writes a listing of the stations heard over the air.
+func (a *App) WriteHeard(w io.Writer) {
+	for method, heard := range a.Heard() {
+		fmt.Fprintf(w, "%s:\n", method)
+		for _, v := range heard {
+			fmt.Fprintf(w, "  %-10s (%s)\n", v.Callsign, v.Time.Format(time.RFC1123))
+		}
+	}
+}
+
 func (a *App) GetStatus() types.Status {
 	configHash := func(c cfg.Config) string {
 		h := sha1.New()
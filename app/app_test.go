@@ -0,0 +1,37 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+// newTestApp returns an App (with callsign N0CALL) using an empty mailbox in a temporary directory.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	a := New(Options{MyCall: "N0CALL"})
+	a.mbox = mailbox.NewDirHandler(t.TempDir(), false)
+	if err := a.mbox.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	a.mboxIndex = OpenMailboxIndex(a.mbox.MBoxPath)
+	return a
+}
+
+// addInbound stores a message from LA5NTA to N0CALL in the inbox for each of the given subjects.
+func addInbound(t *testing.T, a *App, subjects ...string) []*fbb.Message {
+	t.Helper()
+	msgs := make([]*fbb.Message, 0, len(subjects))
+	for _, subject := range subjects {
+		msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+		msg.AddTo("N0CALL")
+		msg.SetSubject(subject)
+		msg.SetBody("Body")
+		if err := a.mbox.ProcessInbound(msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AutoConnectScheme is the scheme of connect targets resolved to the best
+// RMS gateways from the RMS list (e.g. auto:varahf?band=40m&max=5).
+const AutoConnectScheme = "auto"
+
+const defaultAutoConnectMax = 3
+
+type autoConnectParams struct {
+	Mode   string
+	Band   string
+	Prefix string
+	Max    int
+}
+
+func isAutoConnect(connectStr string) bool {
+	return strings.HasPrefix(connectStr, AutoConnectScheme+":")
+}
+
+// parseAutoConnect parses a connect string on the form auto:<mode>[?band=<band>&prefix=<prefix>&max=<n>].
+func parseAutoConnect(connectStr string) (autoConnectParams, error) {
+	u, err := url.Parse(connectStr)
+	if err != nil {
+		return autoConnectParams{}, err
+	}
+	if u.Scheme != AutoConnectScheme {
+		return autoConnectParams{}, fmt.Errorf("unexpected scheme '%s'", u.Scheme)
+	}
+	p := autoConnectParams{
+		Mode:   strings.ToLower(strings.Trim(u.Opaque+u.Host+u.Path, "/")),
+		Band:   u.Query().Get("band"),
+		Prefix: strings.ToUpper(u.Query().Get("prefix")),
+		Max:    defaultAutoConnectMax,
+	}
+	if p.Mode == "" {
+		return p, fmt.Errorf("missing mode (e.g. %s:%s)", AutoConnectScheme, MethodVaraHF)
+	}
+	if p.Band != "" {
+		if _, ok := bands[p.Band]; !ok {
+			return p, fmt.Errorf("unknown band '%s'", p.Band)
+		}
+	}
+	if v := u.Query().Get("max"); v != "" {
+		p.Max, err = strconv.Atoi(v)
+		if err != nil || p.Max < 1 {
+			return p, fmt.Errorf("invalid max value '%s'", v)
+		}
+	}
+	return p, nil
+}
+
+// autoConnectCandidates returns the connect URLs of the best RMS gateways matching the given auto connect string.
+func (a *App) autoConnectCandidates(ctx context.Context, connectStr string) ([]string, error) {
+	p, err := parseAutoConnect(connectStr)
+	if err != nil {
+		return nil, err
+	}
+	list, err := a.ReadRMSList(ctx, false, func(r RMS) bool {
+		switch {
+		case r.URL == nil:
+			return false
+		case !r.IsMode(p.Mode):
+			return false
+		case p.Band != "" && !r.IsBand(p.Band):
+			return false
+		case p.Prefix != "" && !strings.HasPrefix(r.Callsign, p.Prefix):
+			return false
+		default:
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if a.predictor != nil {
+		sort.Sort(sort.Reverse(ByLinkQuality(list)))
+	} else {
+		sort.Sort(ByDist(list))
+	}
+	if len(list) > p.Max {
+		list = list[:p.Max]
+	}
+	candidates := make([]string, len(list))
+	for i, r := range list {
+		candidates[i] = r.URL.String()
+	}
+	return candidates, nil
+}
+
+func (a *App) connectAuto(connectStr string) (success bool) {
+	log.Printf("Selecting RMS gateways for %s...", connectStr)
+	candidates, err := a.autoConnectCandidates(context.Background(), connectStr)
+	switch {
+	case err != nil:
+		log.Printf("Unable to resolve %s: %v", connectStr, err)
+		return false
+	case len(candidates) == 0:
+		log.Printf("No RMS gateways found matching %s", connectStr)
+		return false
+	}
+	return a.ConnectChain(candidates...)
+}
@@ -0,0 +1,30 @@
+package app
+
+import "testing"
+
+func TestParseAutoConnect(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    autoConnectParams
+		wantErr bool
+	}{
+		{in: "auto:varahf", want: autoConnectParams{Mode: "varahf", Max: defaultAutoConnectMax}},
+		{in: "auto:ARDOP?band=40m&max=5", want: autoConnectParams{Mode: "ardop", Band: "40m", Max: 5}},
+		{in: "auto:///varafm?prefix=la", want: autoConnectParams{Mode: "varafm", Prefix: "LA", Max: defaultAutoConnectMax}},
+		{in: "auto:?band=40m", wantErr: true},
+		{in: "auto:varahf?band=41m", wantErr: true},
+		{in: "auto:varahf?max=0", wantErr: true},
+		{in: "varahf:///LA1B", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseAutoConnect(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAutoConnect(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseAutoConnect(%q) = %#v, want %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+// Bulk mailbox actions.
+const (
+	BulkMove    = "move"
+	BulkDelete  = "delete"
+	BulkRead    = "read"
+	BulkUnread  = "unread"
+	BulkArchive = "archive"
+)
+
+// Status of an item in a bulk operation.
+const (
+	BulkStatusOK         = "ok"
+	BulkStatusError      = "error"       // The item failed validation, or could not be applied.
+	BulkStatusSkipped    = "skipped"     // The item was not applied because another item failed.
+	BulkStatusRolledBack = "rolled_back" // The item was applied, but reverted because another item failed.
+)
+
+// ErrBulkFailed is returned when a bulk operation was not applied. See the per-item results for details.
+var ErrBulkFailed = errors.New("bulk operation failed")
+
+// BulkResult is the result of a bulk operation for a single message.
+type BulkResult struct {
+	MID    string `json:"mid"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkUpdate applies the action to all messages with the given MIDs in the given folder.
+//
+// The operation is all-or-nothing: all items are validated before any changes are made, and
+// applied items are reverted if a later item fails. Permanent deletes (from the trash) can not
+// be reverted. Target is the destination folder of the move action.
+func (a *App) BulkUpdate(folder, action string, mids []string, target string) ([]BulkResult, error) {
+	switch action {
+	case BulkArchive:
+		target = "archive"
+	case BulkMove:
+		if dir, err := a.folderPath(target); err != nil {
+			return nil, err
+		} else if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return nil, ErrFolderNotFound
+		}
+		if target == "out" {
+			return nil, fmt.Errorf("messages can not be moved to the outbox")
+		}
+	case BulkDelete, BulkRead, BulkUnread:
+	default:
+		return nil, fmt.Errorf("invalid action '%s'", action)
+	}
+
+	results, files, ok := a.validateBulk(folder, mids)
+	if !ok {
+		return results, ErrBulkFailed
+	}
+
+	undo := make([]func() error, 0, len(mids))
+	for i, mid := range mids {
+		revert, err := a.applyBulk(action, folder, target, mid, files[i])
+		if err == nil {
+			undo = append(undo, revert)
+			results[i].Status = BulkStatusOK
+			continue
+		}
+		results[i].Status, results[i].Error = BulkStatusError, err.Error()
+		for j := i - 1; j >= 0; j-- {
+			if undo[j] == nil {
+				continue // Can't be reverted.
+			}
+			if err := undo[j](); err != nil {
+				results[j].Error = fmt.Sprintf("rollback failed: %v", err)
+				continue
+			}
+			results[j].Status = BulkStatusRolledBack
+		}
+		return results, ErrBulkFailed
+	}
+	return results, nil
+}
+
+// validateBulk checks that all messages exist in the folder, returning the initial results and the message files.
+func (a *App) validateBulk(folder string, mids []string) ([]BulkResult, []string, bool) {
+	results := make([]BulkResult, len(mids))
+	files := make([]string, len(mids))
+	ok := len(mids) > 0
+	for i, mid := range mids {
+		results[i] = BulkResult{MID: mid, Status: BulkStatusSkipped}
+		file, err := a.messageFile(folder, mid)
+		switch {
+		case slices.Index(mids, mid) != i:
+			err = fmt.Errorf("duplicate message id")
+		case os.IsNotExist(err):
+			err = fmt.Errorf("message not found")
+		}
+		if err != nil {
+			results[i].Status, results[i].Error = BulkStatusError, err.Error()
+			ok = false
+		}
+		files[i] = file
+	}
+	return results, files, ok
+}
+
+// applyBulk applies the action to a single message, returning a function reverting the change (if possible).
+func (a *App) applyBulk(action, folder, target, mid, file string) (func() error, error) {
+	switch action {
+	case BulkMove, BulkArchive:
+		dst, err := a.moveMessageFile(file, target)
+		if err != nil {
+			return nil, err
+		}
+		return func() error { return os.Rename(dst, file) }, nil
+	case BulkDelete:
+		if err := a.DeleteMessage(mid, folder); err != nil || folder == TrashFolder {
+			return nil, err
+		}
+		return func() error { _, err := a.RestoreMessage(mid); return err }, nil
+	case BulkRead, BulkUnread:
+		msg, err := mailbox.OpenMessage(file)
+		if err != nil {
+			return nil, err
+		}
+		unread := mailbox.IsUnread(msg)
+		if err := mailbox.SetUnread(msg, action == BulkUnread); err != nil {
+			return nil, err
+		}
+		return func() error { return mailbox.SetUnread(msg, unread) }, nil
+	default:
+		panic("unexpected action " + action)
+	}
+}
+
+// ExportMessages writes the raw message files with the given MIDs in the given folder as a zip archive.
+//
+// All messages are validated before anything is written. On validation failure, ErrBulkFailed is returned along with the per-item results.
+func (a *App) ExportMessages(w io.Writer, folder string, mids []string) ([]BulkResult, error) {
+	results, files, ok := a.validateBulk(folder, mids)
+	if !ok {
+		return results, ErrBulkFailed
+	}
+	zw := zip.NewWriter(w)
+	for i, file := range files {
+		if err := addZipFile(zw, file); err != nil {
+			return results, err
+		}
+		results[i].Status = BulkStatusOK
+	}
+	return results, zw.Close()
+}
+
+func addZipFile(zw *zip.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return err
+	}
+	hdr.Name, hdr.Method = filepath.Base(file), zip.Deflate
+	dst, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	return err
+}
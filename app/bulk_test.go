@@ -0,0 +1,94 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+func TestBulkUpdate(t *testing.T) {
+	a := newTestApp(t)
+	if err := a.CreateFolder("eoc"); err != nil {
+		t.Fatal(err)
+	}
+	var mids []string
+	for _, msg := range addInbound(t, a, "One", "Two", "Three") {
+		mids = append(mids, msg.MID())
+	}
+	inbox := func() int { msgs, _ := a.FolderMessages("in"); return len(msgs) }
+
+	// Validation failure: nothing is applied.
+	results, err := a.BulkUpdate("in", BulkMove, []string{mids[0], "MISSING", mids[0]}, "eoc")
+	if !errors.Is(err, ErrBulkFailed) {
+		t.Fatalf("expected ErrBulkFailed, got %v", err)
+	}
+	expect := []string{BulkStatusSkipped, BulkStatusError, BulkStatusError}
+	for i, r := range results {
+		if r.Status != expect[i] {
+			t.Errorf("item %d: expected %s, got %s (%s)", i, expect[i], r.Status, r.Error)
+		}
+	}
+	if inbox() != 3 {
+		t.Error("expected no messages to be moved")
+	}
+
+	// Failure while applying: applied items are rolled back.
+	if err := os.Mkdir(filepath.Join(a.mbox.MBoxPath, "eoc", mids[1]+mailbox.Ext), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	results, err = a.BulkUpdate("in", BulkMove, mids[:2], "eoc")
+	if !errors.Is(err, ErrBulkFailed) || results[0].Status != BulkStatusRolledBack || results[1].Status != BulkStatusError {
+		t.Errorf("unexpected results (%v): %+v", err, results)
+	}
+	if inbox() != 3 {
+		t.Error("expected moved message to be rolled back")
+	}
+
+	if _, err := a.BulkUpdate("in", BulkRead, mids, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range mustFolders(t, a) {
+		if f.Name == "in" && f.Unread != 0 {
+			t.Errorf("expected all messages to be read, got %d unread", f.Unread)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := a.ExportMessages(&buf, "in", mids[1:]); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 2 || zr.File[0].Name != mids[1]+mailbox.Ext {
+		t.Errorf("unexpected archive content: %v", zr.File)
+	}
+
+	if _, err := a.BulkUpdate("in", BulkDelete, mids[1:], ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.BulkUpdate("in", BulkArchive, mids[:1], ""); err != nil {
+		t.Fatal(err)
+	}
+	if inbox() != 0 {
+		t.Errorf("expected empty inbox, got %d messages", inbox())
+	}
+	if _, err := a.BulkUpdate("in", "explode", mids, ""); err == nil || errors.Is(err, ErrBulkFailed) {
+		t.Errorf("expected invalid action error, got %v", err)
+	}
+}
+
+func mustFolders(t *testing.T, a *App) []Folder {
+	t.Helper()
+	folders, err := a.Folders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return folders
+}
@@ -136,6 +136,16 @@ func LoadConfig(cfgPath string, fallback cfg.Config) (config cfg.Config, err err
 		config.Ardop.ConnectRequests = cfg.DefaultConfig.Ardop.ConnectRequests
 	}
 
+	// Ensure SMTP.Addr has a default value (an empty address would listen on all interfaces)
+	if config.SMTP.Addr == "" {
+		config.SMTP.Addr = cfg.DefaultConfig.SMTP.Addr
+	}
+
+	// Ensure IMAP.Addr has a default value (an empty address would listen on all interfaces)
+	if config.IMAP.Addr == "" {
+		config.IMAP.Addr = cfg.DefaultConfig.IMAP.Addr
+	}
+
 	return config, nil
 }
 
@@ -2,6 +2,7 @@ package app
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -60,3 +61,20 @@ func TestReadRigsFromEnv(t *testing.T) {
 		}
 	})
 }
+
+func TestLoadConfigDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"smtp": {"enabled": true}, "imap": {"enabled": true}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path, cfg.DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.SMTP.Enabled || config.SMTP.Addr != cfg.DefaultConfig.SMTP.Addr {
+		t.Errorf("unexpected SMTP config: %+v", config.SMTP)
+	}
+	if !config.IMAP.Enabled || config.IMAP.Addr != cfg.DefaultConfig.IMAP.Addr {
+		t.Errorf("unexpected IMAP config: %+v", config.IMAP)
+	}
+}
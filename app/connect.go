@@ -32,11 +32,58 @@ import (
 
 func hasSSID(str string) bool { return strings.Contains(str, "-") }
 
+// ConnectChain attempts to connect to each of the given connect strings (URLs, aliases or chains)
+// in turn, until a session succeeds or the user aborts.
+func (a *App) ConnectChain(chain ...string) (success bool) {
+	a.abortRequested.Store(false)
+	return a.connectChain(chain, map[string]bool{})
+}
+
+// connectChain is ConnectChain, where visited holds the aliases and chains being resolved (to
+// guard against recursion). Nested chains are expanded in place.
+func (a *App) connectChain(chain []string, visited map[string]bool) (success bool) {
+	for i, connectStr := range chain {
+		if len(chain) > 1 {
+			log.Printf("Connect attempt %d/%d: %s", i+1, len(chain), connectStr)
+		}
+		if a.connect(connectStr, visited) {
+			return true
+		}
+		if a.abortRequested.Load() {
+			log.Println("Connect chain aborted")
+			return false
+		}
+	}
+	if len(chain) > 1 {
+		log.Println("All connect attempts failed")
+	}
+	return false
+}
+
 func (a *App) Connect(connectStr string) (success bool) {
+	a.abortRequested.Store(false)
+	return a.connect(connectStr, map[string]bool{})
+}
+
+func (a *App) connect(connectStr string, visited map[string]bool) (success bool) {
+	_, isChain := a.config.ConnectChains[connectStr]
+	aliased, isAlias := a.config.ConnectAliases[connectStr]
+	if (isChain || isAlias) && visited[connectStr] {
+		log.Printf("Skipping recursive connect alias/chain '%s'", connectStr)
+		return false
+	} else if isChain || isAlias {
+		visited[connectStr] = true
+		defer delete(visited, connectStr)
+	}
+
 	if connectStr == "" {
 		return false
-	} else if aliased, ok := a.config.ConnectAliases[connectStr]; ok {
-		return a.Connect(aliased)
+	} else if isChain {
+		return a.connectChain(a.config.ConnectChains[connectStr], visited)
+	} else if isAlias {
+		return a.connect(aliased, visited)
+	} else if isAutoConnect(connectStr) {
+		return a.connectAuto(connectStr)
 	}
 
 	// Replace placeholders
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConnectRecursion(t *testing.T) {
+	a := New(Options{})
+	a.config.ConnectChains = map[string][]string{"ops": {"primary"}}
+	a.config.ConnectAliases = map[string]string{"primary": "ops", "loop-a": "loop-b", "loop-b": "loop-a"}
+	for _, connectStr := range []string{"ops", "primary", "loop-a"} {
+		if a.Connect(connectStr) {
+			t.Errorf("%s: expected connect to fail", connectStr)
+		}
+	}
+}
+
+func TestConnectNestedChain(t *testing.T) {
+	a := New(Options{})
+	a.config.ConnectChains = map[string][]string{"all": {"hf", "b"}, "hf": {"a", "c"}}
+	a.config.ConnectAliases = map[string]string{"a": "a", "b": "b", "c": "c"} // Fails without dialing.
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	if a.ConnectChain("all") {
+		t.Fatal("expected connect to fail")
+	}
+	for _, attempt := range []string{"1/2: hf", "1/2: a", "2/2: c", "2/2: b"} {
+		if !strings.Contains(buf.String(), "Connect attempt "+attempt) {
+			t.Errorf("expected attempt %s, got log:\n%s", attempt, buf.String())
+		}
+	}
+}
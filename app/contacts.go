@@ -0,0 +1,395 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/la5nta/pat/internal/debug"
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+const contactsFileName = "contacts.json"
+
+var (
+	ErrContactNotFound = errors.New("contact not found")
+	ErrContactExists   = errors.New("contact already exists")
+	ErrGroupNotFound   = errors.New("group not found")
+)
+
+// Kinds of contact addresses.
+const (
+	ContactCallsign = "callsign"
+	ContactTactical = "tactical"
+	ContactSMTP     = "smtp"
+)
+
+var callsignPattern = regexp.MustCompile(`^[A-Z0-9]{1,3}[0-9][A-Z0-9]{0,3}[A-Z](-[0-9]{1,2})?$`)
+
+// Contact is an entry in the address book.
+type Contact struct {
+	Address   string    `json:"address"`             // Callsign, tactical address or SMTP address.
+	Kind      string    `json:"kind"`                // Derived from the address: callsign, tactical or smtp.
+	Name      string    `json:"name,omitempty"`      // Name usable as a recipient at compose time.
+	Notes     string    `json:"notes,omitempty"`     // Free text.
+	Harvested bool      `json:"harvested,omitempty"` // Added automatically from a sent or received message.
+	LastSeen  time.Time `json:"last_seen,omitempty"` // Date of the last message exchanged with the contact.
+}
+
+// ContactGroup is a named distribution list.
+type ContactGroup struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"` // Addresses, contact names or names of other groups.
+}
+
+// AddressBook is the persistent store of contacts and groups, kept beside the config file.
+//
+// The file is re-read on every access, so manual edits take effect immediately.
+type AddressBook struct {
+	path string
+	mu   sync.Mutex
+}
+
+type addressBookData struct {
+	Contacts []Contact      `json:"contacts"`
+	Groups   []ContactGroup `json:"groups"`
+}
+
+// OpenAddressBook returns the address book stored in the given file.
+func OpenAddressBook(path string) *AddressBook { return &AddressBook{path: path} }
+
+func (b *AddressBook) load() (addressBookData, error) {
+	var data addressBookData
+	f, err := os.Open(b.path)
+	if os.IsNotExist(err) {
+		return data, nil
+	} else if err != nil {
+		return data, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&data); err != nil {
+		return data, fmt.Errorf("invalid address book %s: %w", b.path, err)
+	}
+	return data, nil
+}
+
+func (b *AddressBook) save(data addressBookData) error {
+	sort.SliceStable(data.Contacts, func(i, j int) bool { return data.Contacts[i].Address < data.Contacts[j].Address })
+	sort.SliceStable(data.Groups, func(i, j int) bool {
+		return strings.ToLower(data.Groups[i].Name) < strings.ToLower(data.Groups[j].Name)
+	})
+	buf, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(b.path+".tmp", buf, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(b.path+".tmp", b.path)
+}
+
+// update loads the address book, applies fn and saves the result (unless fn fails).
+func (b *AddressBook) update(fn func(data *addressBookData) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, err := b.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(&data); err != nil {
+		return err
+	}
+	return b.save(data)
+}
+
+// Contacts returns the contacts with an address or name containing query (case-insensitive), or all if empty.
+func (b *AddressBook) Contacts(query string) ([]Contact, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, err := b.load()
+	if err != nil || query == "" {
+		return data.Contacts, err
+	}
+	query = strings.ToLower(query)
+	var matches []Contact
+	for _, c := range data.Contacts {
+		if strings.Contains(strings.ToLower(c.Address), query) || strings.Contains(strings.ToLower(c.Name), query) {
+			matches = append(matches, c)
+		}
+	}
+	return matches, nil
+}
+
+// Contact returns the contact with the given address.
+func (b *AddressBook) Contact(addr string) (Contact, error) {
+	contacts, err := b.Contacts("")
+	if err != nil {
+		return Contact{}, err
+	}
+	if i := indexContact(contacts, addr); i >= 0 {
+		return contacts[i], nil
+	}
+	return Contact{}, ErrContactNotFound
+}
+
+// AddContact adds a new contact.
+func (b *AddressBook) AddContact(c Contact) (Contact, error) {
+	if err := normalizeContact(&c); err != nil {
+		return c, err
+	}
+	return c, b.update(func(data *addressBookData) error {
+		if indexContact(data.Contacts, c.Address) >= 0 {
+			return ErrContactExists
+		}
+		data.Contacts = append(data.Contacts, c)
+		return nil
+	})
+}
+
+// UpdateContact replaces the contact with the given address. The address may be changed.
+func (b *AddressBook) UpdateContact(addr string, c Contact) (Contact, error) {
+	if err := normalizeContact(&c); err != nil {
+		return c, err
+	}
+	return c, b.update(func(data *addressBookData) error {
+		i := indexContact(data.Contacts, addr)
+		if i < 0 {
+			return ErrContactNotFound
+		}
+		if j := indexContact(data.Contacts, c.Address); j >= 0 && j != i {
+			return ErrContactExists
+		}
+		c.LastSeen = data.Contacts[i].LastSeen // Maintained by harvesting only.
+		data.Contacts[i] = c
+		return nil
+	})
+}
+
+// DeleteContact removes the contact with the given address.
+func (b *AddressBook) DeleteContact(addr string) error {
+	return b.update(func(data *addressBookData) error {
+		i := indexContact(data.Contacts, addr)
+		if i < 0 {
+			return ErrContactNotFound
+		}
+		data.Contacts = slices.Delete(data.Contacts, i, i+1)
+		return nil
+	})
+}
+
+// Groups returns all groups.
+func (b *AddressBook) Groups() ([]ContactGroup, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, err := b.load()
+	return data.Groups, err
+}
+
+// Group returns the group with the given name (case-insensitive).
+func (b *AddressBook) Group(name string) (ContactGroup, error) {
+	groups, err := b.Groups()
+	if err != nil {
+		return ContactGroup{}, err
+	}
+	if i := indexGroup(groups, name); i >= 0 {
+		return groups[i], nil
+	}
+	return ContactGroup{}, ErrGroupNotFound
+}
+
+// PutGroup creates or replaces the group with the given name.
+func (b *AddressBook) PutGroup(g ContactGroup) error {
+	g.Name = strings.TrimSpace(g.Name)
+	switch {
+	case g.Name == "" || strings.ContainsFunc(g.Name, SplitFunc):
+		return fmt.Errorf("invalid group name '%s'", g.Name)
+	case len(g.Members) == 0:
+		return fmt.Errorf("group '%s' has no members", g.Name)
+	}
+	return b.update(func(data *addressBookData) error {
+		if i := indexGroup(data.Groups, g.Name); i >= 0 {
+			data.Groups[i] = g
+		} else {
+			data.Groups = append(data.Groups, g)
+		}
+		return nil
+	})
+}
+
+// DeleteGroup removes the group with the given name.
+func (b *AddressBook) DeleteGroup(name string) error {
+	return b.update(func(data *addressBookData) error {
+		i := indexGroup(data.Groups, name)
+		if i < 0 {
+			return ErrGroupNotFound
+		}
+		data.Groups = slices.Delete(data.Groups, i, i+1)
+		return nil
+	})
+}
+
+// Expand resolves group names and contact names (case-insensitive) to recipient addresses.
+//
+// Groups take precedence over contact names, and other recipients are returned as-is. Duplicate
+// addresses are removed.
+func (b *AddressBook) Expand(rcpts []string) ([]string, error) {
+	b.mu.Lock()
+	data, err := b.load()
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	var expand func(rcpt string, visited []string)
+	expand = func(rcpt string, visited []string) {
+		rcpt = strings.TrimSpace(rcpt)
+		if i := indexGroup(data.Groups, rcpt); i >= 0 {
+			if slices.Contains(visited, data.Groups[i].Name) {
+				return // Nested reference to itself.
+			}
+			for _, m := range data.Groups[i].Members {
+				expand(m, append(visited, data.Groups[i].Name))
+			}
+			return
+		}
+		if i := slices.IndexFunc(data.Contacts, func(c Contact) bool { return c.Name != "" && strings.EqualFold(c.Name, rcpt) }); i >= 0 {
+			rcpt = data.Contacts[i].Address
+		}
+		if rcpt != "" && !slices.ContainsFunc(addrs, func(a string) bool { return strings.EqualFold(a, rcpt) }) {
+			addrs = append(addrs, rcpt)
+		}
+	}
+	for _, rcpt := range rcpts {
+		expand(rcpt, nil)
+	}
+	return addrs, nil
+}
+
+// Harvest adds the given correspondents as contacts, or updates the last seen date of existing contacts.
+func (b *AddressBook) Harvest(date time.Time, addrs ...fbb.Address) (int, error) {
+	var added int
+	err := b.update(func(data *addressBookData) error {
+		for _, addr := range addrs {
+			if addr.IsZero() {
+				continue
+			}
+			c := Contact{Address: addr.Addr, Harvested: true, LastSeen: date}
+			if err := normalizeContact(&c); err != nil {
+				continue
+			}
+			if i := indexContact(data.Contacts, c.Address); i >= 0 {
+				if date.After(data.Contacts[i].LastSeen) {
+					data.Contacts[i].LastSeen = date
+				}
+				continue
+			}
+			data.Contacts = append(data.Contacts, c)
+			added++
+		}
+		return nil
+	})
+	return added, err
+}
+
+// normalizeContact validates the contact, canonicalizing the address and setting the kind.
+func normalizeContact(c *Contact) error {
+	c.Name, c.Notes = strings.TrimSpace(c.Name), strings.TrimSpace(c.Notes)
+	addr := fbb.AddressFromString(strings.TrimSpace(c.Address))
+	if addr.IsZero() || strings.ContainsFunc(addr.Addr, SplitFunc) {
+		return fmt.Errorf("invalid address '%s'", c.Address)
+	}
+	switch {
+	case addr.Proto != "":
+		c.Kind = ContactSMTP
+	case callsignPattern.MatchString(strings.ToUpper(addr.Addr)):
+		c.Kind = ContactCallsign
+	default:
+		c.Kind = ContactTactical
+	}
+	if addr.Proto == "" {
+		addr.Addr = strings.ToUpper(addr.Addr)
+	}
+	c.Address = addr.Addr // SMTP addresses are recognized by the @.
+	return nil
+}
+
+func indexContact(contacts []Contact, addr string) int {
+	addr = fbb.AddressFromString(strings.TrimSpace(addr)).Addr
+	return slices.IndexFunc(contacts, func(c Contact) bool { return strings.EqualFold(c.Address, addr) })
+}
+
+func indexGroup(groups []ContactGroup, name string) int {
+	return slices.IndexFunc(groups, func(g ContactGroup) bool { return strings.EqualFold(g.Name, strings.TrimSpace(name)) })
+}
+
+// AddressBook returns the address book.
+func (a *App) AddressBook() *AddressBook { return a.contacts }
+
+// ExpandRecipients resolves address book groups and contact names in the recipients of a message being composed.
+//
+// Errors reading the address book are logged, and the recipients are returned as-is.
+func (a *App) ExpandRecipients(rcpts []string) []string {
+	if a.contacts == nil {
+		return rcpts
+	}
+	addrs, err := a.contacts.Expand(rcpts)
+	if err != nil {
+		debug.Printf("Unable to expand recipients: %v", err)
+		return rcpts
+	}
+	return addrs
+}
+
+// harvestContacts adds the correspondents of a sent or received message to the address book (unless disabled).
+func (a *App) harvestContacts(msg *fbb.Message, sent bool) {
+	if a.contacts == nil || a.config.ContactHarvestingDisabled || isServiceMessage(msg) {
+		return
+	}
+	addrs := []fbb.Address{msg.From()}
+	if sent {
+		addrs = msg.Receivers()
+	}
+	addrs = slices.DeleteFunc(addrs, func(addr fbb.Address) bool { return addr.EqualString(a.options.MyCall) })
+	if _, err := a.contacts.Harvest(msg.Date(), addrs...); err != nil {
+		debug.Printf("Unable to harvest contacts: %v", err)
+	}
+}
+
+// HarvestMailbox adds the correspondents of all messages in the inbox and sent folders to the address book.
+func (a *App) HarvestMailbox() (int, error) {
+	var added int
+	for _, folder := range []string{"in", "sent"} {
+		msgs, _, err := a.IndexedMessages(folder, MailboxQuery{Asc: true})
+		if err != nil {
+			return added, err
+		}
+		for _, m := range msgs {
+			addrs := []fbb.Address{m.From}
+			if folder == "sent" {
+				addrs = append(slices.Clone(m.To), m.Cc...)
+			}
+			addrs = slices.DeleteFunc(addrs, func(addr fbb.Address) bool { return addr.EqualString(a.options.MyCall) })
+			n, err := a.contacts.Harvest(m.Date, addrs...)
+			if err != nil {
+				return added, err
+			}
+			added += n
+		}
+	}
+	return added, nil
+}
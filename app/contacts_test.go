@@ -0,0 +1,107 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+func TestAddressBook(t *testing.T) {
+	book := OpenAddressBook(filepath.Join(t.TempDir(), contactsFileName))
+	for _, c := range []Contact{
+		{Address: "la5nta", Name: "Martin"},
+		{Address: "EOC-1@winlink.org", Name: "County EOC"},
+		{Address: "ops@example.com"},
+	} {
+		if _, err := book.AddContact(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := book.AddContact(Contact{Address: "LA5NTA"}); !errors.Is(err, ErrContactExists) {
+		t.Errorf("expected ErrContactExists, got %v", err)
+	}
+	if _, err := book.AddContact(Contact{Address: "a,b"}); err == nil {
+		t.Error("expected invalid address to be rejected")
+	}
+	contacts, err := book.Contacts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]string{"LA5NTA": ContactCallsign, "EOC-1": ContactTactical, "ops@example.com": ContactSMTP}
+	for _, c := range contacts {
+		if kinds[c.Address] != c.Kind {
+			t.Errorf("%s: expected kind %q, got %q", c.Address, kinds[c.Address], c.Kind)
+		}
+	}
+	if len(contacts) != 3 {
+		t.Errorf("expected 3 contacts, got %v", contacts)
+	}
+
+	if err := book.PutGroup(ContactGroup{Name: "liaisons", Members: []string{"county eoc", "ops@example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := book.PutGroup(ContactGroup{Name: "all", Members: []string{"Martin", "LIAISONS", "all"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := book.Expand([]string{"all", "N0CALL", "la5nta"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"LA5NTA", "EOC-1", "ops@example.com", "N0CALL"}; !slices.Equal(got, expect) {
+		t.Errorf("expected %v, got %v", expect, got)
+	}
+
+	if _, err := book.UpdateContact("EOC-1", Contact{Address: "EOC-2", Name: "County EOC"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := book.DeleteContact("EOC-1"); !errors.Is(err, ErrContactNotFound) {
+		t.Errorf("expected ErrContactNotFound, got %v", err)
+	}
+	if err := book.DeleteGroup("liaisons"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := book.Group("liaisons"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("expected ErrGroupNotFound, got %v", err)
+	}
+
+	date := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	n, err := book.Harvest(date, fbb.AddressFromString("LA5NTA"), fbb.AddressFromString("LA1B"))
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 contact harvested, got %d (%v)", n, err)
+	}
+	if c, _ := book.Contact("LA5NTA"); c.Harvested || !c.LastSeen.Equal(date) || c.Name != "Martin" {
+		t.Errorf("unexpected existing contact after harvest: %+v", c)
+	}
+	if c, _ := book.Contact("la1b"); !c.Harvested || c.Kind != ContactCallsign {
+		t.Errorf("unexpected harvested contact: %+v", c)
+	}
+}
+
+func TestHarvestMailbox(t *testing.T) {
+	a := newTestApp(t)
+	a.contacts = OpenAddressBook(filepath.Join(t.TempDir(), contactsFileName))
+
+	msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+	msg.AddTo("N0CALL")
+	msg.AddCc("LA1B")
+	msg.SetSubject("Hello")
+	msg.SetBody("Body")
+	if err := (NotifyMBox{a.mbox, a}).ProcessInbound(msg); err != nil {
+		t.Fatal(err)
+	}
+	if contacts, _ := a.contacts.Contacts(""); len(contacts) != 1 || contacts[0].Address != "LA5NTA" {
+		t.Errorf("expected sender to be harvested, got %v", contacts)
+	}
+
+	a.contacts = OpenAddressBook(filepath.Join(t.TempDir(), contactsFileName))
+	if n, err := a.HarvestMailbox(); err != nil || n != 1 {
+		t.Errorf("expected 1 contact harvested, got %d (%v)", n, err)
+	}
+	if got := a.ExpandRecipients([]string{"LA5NTA"}); !slices.Equal(got, []string{"LA5NTA"}) {
+		t.Errorf("unexpected expansion: %v", got)
+	}
+}
@@ -5,22 +5,160 @@
 package app
 
 import (
+	"bufio"
 	"encoding/json"
+	"io"
 	"net"
-	"os"
+	"strings"
 	"time"
+
+	"github.com/la5nta/pat/cfg"
+	"github.com/la5nta/pat/internal/debug"
+	"github.com/la5nta/pat/internal/logrotate"
+	"github.com/la5nta/wl2k-go/transport"
+)
+
+// Event types written to the event log.
+const (
+	EventConnect  = "connect"
+	EventExchange = "exchange"
 )
 
+// Event is a record read back from the event log.
+//
+// Only the fields of interest for reporting are decoded.
+type Event struct {
+	What    string    `json:"what"`
+	LogTime time.Time `json:"log_time"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+
+	Network    string `json:"network,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	LocalAddr  string `json:"local_addr,omitempty"`
+
+	// Connect events
+	Operation string    `json:"operation,omitempty"`
+	Freq      Frequency `json:"freq,omitempty"`
+
+	// Exchange events
+	MyCall        string   `json:"mycall,omitempty"`
+	LocalLocator  string   `json:"local_locator,omitempty"`
+	TargetCall    string   `json:"targetcall,omitempty"`
+	Master        bool     `json:"master,omitempty"`
+	Sent          []string `json:"sent,omitempty"`
+	Received      []string `json:"received,omitempty"`
+	BytesSent     int64    `json:"bytes_sent,omitempty"`
+	BytesReceived int64    `json:"bytes_received,omitempty"`
+	Start         int64    `json:"start,omitempty"`
+	End           int64    `json:"end,omitempty"`
+
+	transport string // Transport of the session, resolved by DecodeEvents.
+}
+
+// ConnectURL returns the URL dialed by an outbound connect event, or nil if
+// this is not an outbound connect event.
+func (e Event) ConnectURL() *transport.URL {
+	connectStr, ok := strings.CutPrefix(e.Operation, "connect ")
+	if e.What != EventConnect || !ok {
+		return nil
+	}
+	url, err := transport.ParseURL(connectStr)
+	if err != nil {
+		return nil
+	}
+	return url
+}
+
+// Duration returns the duration of an exchange event.
+func (e Event) Duration() time.Duration {
+	if e.End < e.Start {
+		return 0
+	}
+	return time.Duration(e.End-e.Start) * time.Second
+}
+
+// ReadEvents reads all events from the event log file at the given path, including its rotated
+// generations (oldest first).
+func ReadEvents(path string) ([]Event, error) {
+	f, err := logrotate.OpenAll(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodeEvents(f)
+}
+
+// DecodeEvents decodes events from a JSON-lines stream, skipping any malformed lines.
+func DecodeEvents(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			debug.Printf("Skipping malformed event log line: %v", err)
+			continue
+		}
+		events = append(events, e)
+	}
+	resolveSessionTransports(events)
+	return events, scanner.Err()
+}
+
+// resolveSessionTransports sets the transport of each exchange event to the transport of the
+// connect event that opened the session.
+//
+// The connection network of an exchange is not specific enough on its own (e.g. "vara" is used by
+// both VARA HF and VARA FM).
+func resolveSessionTransports(events []Event) {
+	var connect *Event // The successful connect awaiting its exchange event
+	for i := range events {
+		switch e := &events[i]; e.What {
+		case EventConnect:
+			connect = nil
+			if e.Success {
+				connect = e
+			}
+		case EventExchange:
+			if connect != nil && connect.RemoteAddr == e.RemoteAddr {
+				e.transport = connect.Transport()
+			}
+			connect = nil
+		}
+	}
+}
+
 type EventLogger struct {
-	file *os.File
+	file *logrotate.File
 	enc  *json.Encoder
+
+	subscribers []func(Event)
 }
 
-func NewEventLogger(path string) (*EventLogger, error) {
-	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o666)
-	return &EventLogger{file, json.NewEncoder(file)}, err
+func NewEventLogger(path string, rotation logrotate.Options) (*EventLogger, error) {
+	file, err := logrotate.Open(path, rotation)
+	if err != nil {
+		return &EventLogger{}, err
+	}
+	return &EventLogger{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func logRotateOptions(c cfg.LogRotationConfig) logrotate.Options {
+	return logrotate.Options{
+		MaxSize:  int64(c.MaxSizeMB) << 20,
+		MaxAge:   time.Duration(c.MaxAgeDays) * 24 * time.Hour,
+		Keep:     c.Keep,
+		Compress: c.Compress,
+	}
 }
 
+// Subscribe registers fn to be called with every event written to the log.
+func (l *EventLogger) Subscribe(fn func(Event)) { l.subscribers = append(l.subscribers, fn) }
+
 func (l *EventLogger) Close() error {
 	if l == nil || l.file == nil {
 		return nil
@@ -35,6 +173,17 @@ func (l *EventLogger) Log(what string, event map[string]interface{}) {
 	if err := l.enc.Encode(event); err != nil {
 		panic(err)
 	}
+
+	if len(l.subscribers) == 0 {
+		return
+	}
+	var e Event
+	if b, err := json.Marshal(event); err == nil {
+		_ = json.Unmarshal(b, &e)
+	}
+	for _, fn := range l.subscribers {
+		fn(e)
+	}
 }
 
 func (l *EventLogger) LogConn(op string, freq Frequency, conn net.Conn, err error) {
@@ -0,0 +1,158 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventFilter selects events from the event log.
+//
+// Zero values match any event.
+type EventFilter struct {
+	Since     time.Time
+	Until     time.Time
+	What      string // Event type (connect or exchange).
+	Target    string // Remote station callsign.
+	Transport string // Transport/network (e.g. ardop or telnet).
+	Success   *bool
+}
+
+func (f EventFilter) Match(e Event) bool {
+	switch {
+	case !f.Since.IsZero() && e.LogTime.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.LogTime.Before(f.Until):
+		return false
+	case f.What != "" && !strings.EqualFold(e.What, f.What):
+		return false
+	case f.Target != "" && !strings.EqualFold(e.Target(), f.Target):
+		return false
+	case f.Transport != "" && !strings.EqualFold(e.Transport(), f.Transport):
+		return false
+	case f.Success != nil && e.Success != *f.Success:
+		return false
+	default:
+		return true
+	}
+}
+
+// Target returns the callsign of the remote station of the event, if known.
+func (e Event) Target() string {
+	if e.TargetCall != "" {
+		return e.TargetCall
+	}
+	if url := e.ConnectURL(); url != nil {
+		return url.Target
+	}
+	return e.RemoteAddr
+}
+
+// Transport returns the transport name of the event (e.g. telnet or ax25), if known.
+//
+// It is derived from the connect URL or the accepting listener, or the connection network if
+// neither is known. Exchange events read from the event log take the transport of the connect
+// event that opened the session.
+func (e Event) Transport() string {
+	if e.transport != "" {
+		return e.transport
+	}
+	if url := e.ConnectURL(); url != nil {
+		return transportName(url.Scheme)
+	}
+	if name, ok := strings.CutPrefix(e.Operation, "accept "); ok && e.What == EventConnect {
+		return transportName(name)
+	}
+	return transportName(e.Network)
+}
+
+// Events returns the events in this app's event log matching the given filter.
+func (a *App) Events(filter EventFilter) ([]Event, error) {
+	events, err := ReadEvents(a.options.EventLogPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return []Event{}, nil
+	case err != nil:
+		return nil, err
+	}
+	matches := make([]Event, 0, len(events))
+	for _, e := range events {
+		if filter.Match(e) {
+			matches = append(matches, e)
+		}
+	}
+	return matches, nil
+}
+
+// ParseEventTime parses a point in time given as RFC3339, a date (YYYY-MM-DD),
+// or a duration relative to now (e.g. 36h or 7d).
+func ParseEventTime(str string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, str, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(str, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(str); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s' (expected RFC3339, YYYY-MM-DD or duration like 7d)", str)
+}
+
+// EventsCSVHeader is the header of the CSV representation of events.
+var EventsCSVHeader = []string{"log_time", "what", "operation", "target", "transport", "freq", "success", "error", "sent", "received", "bytes_sent", "bytes_received", "duration"}
+
+// WriteEventsCSV writes the given events as CSV (including header).
+func WriteEventsCSV(w io.Writer, events []Event) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(EventsCSVHeader); err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := cw.Write(e.Fields()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Fields returns the event's values in the order of EventsCSVHeader.
+func (e Event) Fields() []string {
+	var freq, duration string
+	if e.Freq > 0 {
+		freq = fmt.Sprint(e.Freq.KHz())
+	}
+	if e.What == EventExchange {
+		duration = e.Duration().String()
+	}
+	return []string{
+		e.LogTime.Format(time.RFC3339),
+		e.What,
+		e.Operation,
+		e.Target(),
+		e.Transport(),
+		freq,
+		strconv.FormatBool(e.Success),
+		e.Error,
+		strings.Join(e.Sent, " "),
+		strings.Join(e.Received, " "),
+		strconv.FormatInt(e.BytesSent, 10),
+		strconv.FormatInt(e.BytesReceived, 10),
+		duration,
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEventTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2024-03-01T10:00:00Z", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{"7d", now.AddDate(0, 0, -7)},
+		{"36h", now.Add(-36 * time.Hour)},
+	}
+	for _, tt := range tests {
+		got, err := ParseEventTime(tt.in, now)
+		if err != nil {
+			t.Errorf("ParseEventTime(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseEventTime(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	if _, err := ParseEventTime("last week", now); err == nil {
+		t.Error("expected error for invalid time")
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	failed, ok := false, true
+	connect := Event{
+		What:      EventConnect,
+		LogTime:   time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC),
+		Operation: "connect ardop:///LA3F?freq=3595",
+		Success:   false,
+	}
+	tests := []struct {
+		filter EventFilter
+		want   bool
+	}{
+		{EventFilter{}, true},
+		{EventFilter{What: EventConnect, Transport: "ardop", Target: "la3f", Success: &failed}, true},
+		{EventFilter{Success: &ok}, false},
+		{EventFilter{What: EventExchange}, false},
+		{EventFilter{Transport: "varahf"}, false},
+		{EventFilter{Since: connect.LogTime}, true},
+		{EventFilter{Until: connect.LogTime}, false},
+	}
+	for i, tt := range tests {
+		if got := tt.filter.Match(connect); got != tt.want {
+			t.Errorf("%d: Match() = %t, want %t", i, got, tt.want)
+		}
+	}
+
+	// Transport names are matched regardless of the connection network.
+	events := []Event{
+		{What: EventConnect, Operation: "connect telnet:///LA1B", Network: "tcp"},
+		{What: EventExchange, Network: "tcp"},
+		{What: EventConnect, Operation: "connect ax25+linux:///LA1B"},
+		{What: EventExchange, Network: "AX.25"},
+		{What: EventConnect, Operation: "connect varahf:///LA1B", Success: true, Network: "vara", RemoteAddr: "LA1B"},
+		{What: EventExchange, Network: "vara", RemoteAddr: "LA1B"},
+		{What: EventConnect, Operation: "accept varafm", Success: true, Network: "vara", RemoteAddr: "LA3F"},
+		{What: EventExchange, Network: "vara", RemoteAddr: "LA3F"},
+	}
+	resolveSessionTransports(events)
+	for i, e := range events {
+		want := []string{"telnet", "telnet", "ax25", "ax25", "varahf", "varahf", "varafm", "varafm"}[i]
+		if !(EventFilter{Transport: want}).Match(e) {
+			t.Errorf("%d: expected transport %s, got %s", i, want, e.Transport())
+		}
+	}
+}
@@ -12,12 +12,14 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/la5nta/pat/api/types"
 	"github.com/la5nta/pat/internal/buildinfo"
 
 	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
 )
 
 type ex struct {
@@ -71,17 +73,44 @@ func (m NotifyMBox) ProcessInbound(msgs ...*fbb.Message) error {
 		if isServiceMessage(msg) {
 			m.onServiceMessageReceived(msg)
 		}
+		m.notifyMessage(msg)
+		m.harvestContacts(msg, false)
+		m.applyInboundRules(msg)
 	}
 	return nil
 }
 
+// GetOutbound returns the outbound messages to propose, honoring the hold, send after and priority metadata.
+func (m NotifyMBox) GetOutbound(fws ...fbb.Address) []*fbb.Message {
+	return eligibleOutbound(m.MBoxHandler.GetOutbound(fws...), time.Now())
+}
+
+func (m NotifyMBox) SetSent(mid string, rejected bool) {
+	m.MBoxHandler.SetSent(mid, rejected)
+	file, err := m.messageFile("sent", mid)
+	if err != nil {
+		return
+	}
+	if msg, err := mailbox.OpenMessage(file); err == nil {
+		m.harvestContacts(msg, true)
+	}
+}
+
 func (m NotifyMBox) GetInboundAnswers(p []fbb.Proposal) []fbb.ProposalAnswer {
 	answers := make([]fbb.ProposalAnswer, len(p))
+	byRule := make([]bool, len(p)) // True if answered by a proposal rule
+	transport := m.activeTransport()
+	limit := m.autoDownloadSizeLimit(transport)
 	var outsideLimit bool
 	var hasAccountActivation bool
 	for idx, p := range p {
 		answers[idx] = m.GetInboundAnswer(p)
-		outsideLimit = outsideLimit || p.CompressedSize() >= m.config.AutoDownloadSizeLimit
+		if answers[idx] == fbb.Accept {
+			if ans, ok := m.proposalRuleAnswer(&p, transport); ok {
+				answers[idx], byRule[idx] = ans, true
+			}
+		}
+		outsideLimit = outsideLimit || (!byRule[idx] && p.CompressedSize() >= limit)
 		if pm := p.PendingMessage(); pm != nil {
 			hasAccountActivation = hasAccountActivation || isAccountActivation(pm.From, pm.Subject)
 		}
@@ -101,7 +130,7 @@ func (m NotifyMBox) GetInboundAnswers(p []fbb.Proposal) []fbb.ProposalAnswer {
 			return answers
 		}
 	}
-	if !outsideLimit || m.config.AutoDownloadSizeLimit < 0 {
+	if !outsideLimit || limit < 0 {
 		// All proposals are within the prompt limit. Go ahead.
 		return answers
 	}
@@ -109,7 +138,7 @@ func (m NotifyMBox) GetInboundAnswers(p []fbb.Proposal) []fbb.ProposalAnswer {
 	// Build multi-select build options for those accepted by the mailbox handler.
 	var options []PromptOption
 	for idx, p := range p {
-		if answers[idx] != fbb.Accept {
+		if answers[idx] != fbb.Accept || byRule[idx] {
 			continue
 		}
 		answers[idx] = fbb.Defer // Defer unless user explicitly accepts through prompt answer.
@@ -118,7 +147,7 @@ func (m NotifyMBox) GetInboundAnswers(p []fbb.Proposal) []fbb.ProposalAnswer {
 			sender, subject = pm.From.String(), pm.Subject
 		}
 		desc := fmt.Sprintf("%s (%d bytes): %s", sender, p.CompressedSize(), subject)
-		options = append(options, PromptOption{Value: p.MID(), Desc: desc, Checked: p.CompressedSize() < m.config.AutoDownloadSizeLimit})
+		options = append(options, PromptOption{Value: p.MID(), Desc: desc, Checked: p.CompressedSize() < limit})
 	}
 
 	// Prompt the user
@@ -198,7 +227,8 @@ func (a *App) sessionExchange(conn net.Conn, targetCall string, master bool) err
 	session.IsMaster(master)
 	session.SetLogger(log.New(a.termWriter, "", 0))
 
-	session.SetStatusUpdater(StatusUpdate{a.websocketHub})
+	var traffic trafficCounter
+	session.SetStatusUpdater(StatusUpdate{a.websocketHub, &traffic})
 
 	if a.options.Robust {
 		session.SetRobustMode(fbb.RobustForced)
@@ -221,6 +251,8 @@ func (a *App) sessionExchange(conn net.Conn, targetCall string, master bool) err
 		log.Println("Mocking new account msg...")
 		NotifyMBox{a.mbox, a}.ProcessInbound(mockNewAccountMsg())
 	}
+	// Run the deferred inbound rule actions of received messages without holding up the caller.
+	go a.runInboundActions()
 
 	event := map[string]interface{}{
 		"mycall":              session.Mycall(),
@@ -235,6 +267,8 @@ func (a *App) sessionExchange(conn net.Conn, targetCall string, master bool) err
 		"local_addr":          conn.LocalAddr().String(),
 		"sent":                stats.Sent,
 		"received":            stats.Received,
+		"bytes_sent":          traffic.sent.Load(),
+		"bytes_received":      traffic.received.Load(),
 		"start":               start.Unix(),
 		"end":                 time.Now().Unix(),
 		"success":             err == nil,
@@ -256,11 +290,13 @@ func (a *App) AbortActiveConnection(dirty bool) (ok bool) {
 	case a.dialing != nil:
 		// If we're currently dialing a transport, attempt to abort by cancelling the associated context.
 		log.Printf("Got abort signal while dialing %s, cancelling...", a.dialing.Scheme)
+		a.abortRequested.Store(true)
 		go a.dialCancelFunc()
 		return true
 	case a.exchangeConn != nil:
 		// If we have an active connection, close it gracefully.
 		log.Println("Got abort signal, disconnecting...")
+		a.abortRequested.Store(true)
 		go a.exchangeConn.Close()
 		return true
 	}
@@ -321,9 +357,24 @@ func (a *App) AbortActiveConnection(dirty bool) (ok bool) {
 	}
 }
 
-type StatusUpdate struct{ WSHub }
+// trafficCounter counts the (compressed) message bytes transferred in a session.
+type trafficCounter struct{ sent, received atomic.Int64 }
+
+type StatusUpdate struct {
+	WSHub
+	traffic *trafficCounter
+}
 
 func (s StatusUpdate) UpdateStatus(stat fbb.Status) {
+	if stat.Done && s.traffic != nil {
+		switch {
+		case stat.Receiving != nil:
+			s.traffic.received.Add(int64(stat.BytesTransferred))
+		case stat.Sending != nil:
+			s.traffic.sent.Add(int64(stat.BytesTransferred))
+		}
+	}
+
 	var prop fbb.Proposal
 	switch {
 	case stat.Receiving != nil:
@@ -6,7 +6,10 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"strconv"
 	"strings"
 
@@ -62,6 +65,39 @@ func (f Frequency) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// UnmarshalJSON accepts both the object representation produced by MarshalJSON and a plain number (Hz).
+func (f *Frequency) UnmarshalJSON(b []byte) error {
+	var hz json.Number
+	if err := json.Unmarshal(b, &hz); err != nil {
+		var obj struct {
+			Hz json.Number `json:"hz"`
+		}
+		if err := json.Unmarshal(b, &obj); err != nil {
+			return err
+		}
+		hz = obj.Hz
+	}
+	if hz == "" {
+		return nil
+	}
+	v, err := hz.Float64()
+	if err != nil {
+		return err
+	}
+	*f = Frequency(math.Round(v))
+	return nil
+}
+
+// Band returns the name of the amateur band containing f (e.g. 40m), or an empty string if unknown.
+func (f Frequency) Band() string {
+	for name, band := range bands {
+		if band.Contains(f) {
+			return name
+		}
+	}
+	return ""
+}
+
 func (f Frequency) KHz() float64 { return float64(f) / 1e3 }
 func (f Frequency) MHz() float64 { return float64(f) / 1e6 }
 
@@ -91,6 +127,34 @@ func (f Frequency) Dial(mode string) Frequency {
 	return f + shift
 }
 
+// Freq handles the freq command: the rig frequency of the given transport is written to w, or set
+// if a frequency (kHz) is given (<transport>[:<frequency>]).
+func (a *App) Freq(w io.Writer, param string) error {
+	transport, freq, set := strings.Cut(param, ":")
+	if transport == "" || (set && freq == "") {
+		return errors.New("syntax: freq <transport>[:<frequency>]")
+	}
+	rig, rigName, ok, err := a.VFOForTransport(transport)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("rig '%s' not loaded", rigName)
+	}
+	if !set {
+		freq, err := rig.GetFreq()
+		if err != nil {
+			return fmt.Errorf("unable to get frequency: %w", err)
+		}
+		fmt.Fprintf(w, "%.3f\n", float64(freq)/1e3)
+		return nil
+	}
+	if _, _, err := SetFreq(rig, freq); err != nil {
+		return fmt.Errorf("unable to set frequency: %w", err)
+	}
+	return nil
+}
+
 func SetFreq(rig hamlib.VFO, freq string) (newFreq, oldFreq int, err error) {
 	oldFreq, err = rig.GetFreq()
 	if err != nil {
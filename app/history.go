@@ -0,0 +1,156 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GatewayStats holds connection statistics for a gateway, derived from the event log.
+type GatewayStats struct {
+	Attempts       int         `json:"attempts"`
+	Successes      int         `json:"successes"`
+	SuccessRate    JSONFloat64 `json:"success_rate"`    // Fraction of attempts resulting in a successful exchange (0-1).
+	MeanThroughput JSONFloat64 `json:"mean_throughput"` // Mean message throughput of successful exchanges (bytes/s).
+	LastSuccess    *time.Time  `json:"last_success"`
+
+	throughputSum float64
+	throughputN   int
+}
+
+func (s *GatewayStats) add(o GatewayStats) {
+	s.Attempts += o.Attempts
+	s.Successes += o.Successes
+	s.throughputSum += o.throughputSum
+	s.throughputN += o.throughputN
+	if o.LastSuccess != nil && (s.LastSuccess == nil || o.LastSuccess.After(*s.LastSuccess)) {
+		s.LastSuccess = o.LastSuccess
+	}
+	s.update()
+}
+
+func (s *GatewayStats) update() {
+	s.SuccessRate = JSONFloat64(math.NaN())
+	if s.Attempts > 0 {
+		s.SuccessRate = JSONFloat64(float64(s.Successes) / float64(s.Attempts))
+	}
+	s.MeanThroughput = JSONFloat64(math.NaN())
+	if s.throughputN > 0 {
+		s.MeanThroughput = JSONFloat64(s.throughputSum / float64(s.throughputN))
+	}
+}
+
+type historyKey struct {
+	callsign string
+	freq     Frequency
+}
+
+// History is a store of per-gateway/per-frequency connection statistics.
+type History struct {
+	byFreq map[historyKey]*GatewayStats
+	byCall map[string]*GatewayStats
+}
+
+// LoadHistory builds the gateway connection history from the event log file at the given path.
+//
+// An empty history is returned if the event log does not exist.
+func LoadHistory(eventLogPath string) (*History, error) {
+	events, err := ReadEvents(eventLogPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return NewHistory(events), nil
+}
+
+// NewHistory builds the gateway connection history from the given events.
+//
+// Each outbound connect event is counted as an attempt. The attempt is
+// successful if the connection was established and the following exchange
+// event reports success.
+func NewHistory(events []Event) *History {
+	h := &History{
+		byFreq: make(map[historyKey]*GatewayStats),
+		byCall: make(map[string]*GatewayStats),
+	}
+	var pending *historyKey // The successful connect awaiting it's exchange event
+	for _, e := range events {
+		switch e.What {
+		case EventConnect:
+			pending = nil
+			url := e.ConnectURL()
+			if url == nil {
+				continue
+			}
+			key := historyKey{strings.ToUpper(url.Target), e.Freq}
+			if f, err := strconv.ParseFloat(url.Params.Get("freq"), 64); err == nil {
+				key.freq = Frequency(math.Round(f * 1e3))
+			}
+			h.stats(key).Attempts++
+			if e.Success {
+				pending = &key
+			}
+		case EventExchange:
+			if pending == nil || e.Master {
+				continue
+			}
+			key := *pending
+			pending = nil
+			if !e.Success {
+				continue
+			}
+			s := h.stats(key)
+			s.Successes++
+			t := e.LogTime
+			s.LastSuccess = &t
+			if d := e.Duration(); d > 0 && e.BytesSent+e.BytesReceived > 0 {
+				s.throughputSum += float64(e.BytesSent+e.BytesReceived) / d.Seconds()
+				s.throughputN++
+			}
+		}
+	}
+	for key, s := range h.byFreq {
+		s.update()
+		if _, ok := h.byCall[key.callsign]; !ok {
+			h.byCall[key.callsign] = &GatewayStats{}
+		}
+		h.byCall[key.callsign].add(*s)
+	}
+	return h
+}
+
+func (h *History) stats(key historyKey) *GatewayStats {
+	s, ok := h.byFreq[key]
+	if !ok {
+		s = &GatewayStats{}
+		h.byFreq[key] = s
+	}
+	return s
+}
+
+// Stats returns the statistics for the given gateway on the given (dial) frequency.
+func (h *History) Stats(callsign string, freq Frequency) (GatewayStats, bool) {
+	s, ok := h.byFreq[historyKey{strings.ToUpper(callsign), freq}]
+	if !ok {
+		return GatewayStats{}, false
+	}
+	return *s, true
+}
+
+// GatewayStats returns the statistics for the given gateway across all frequencies.
+func (h *History) GatewayStats(callsign string) (GatewayStats, bool) {
+	s, ok := h.byCall[strings.ToUpper(callsign)]
+	if !ok {
+		return GatewayStats{}, false
+	}
+	return *s, true
+}
+
+// History returns the gateway connection history derived from this app's event log.
+func (a *App) History() (*History, error) { return LoadHistory(a.options.EventLogPath) }
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewHistory(t *testing.T) {
+	const eventLog = `
+{"log_time":"2024-01-01T10:00:00Z","what":"connect","operation":"connect varahf:///LA1B?freq=7103.5","success":false,"error":"timeout"}
+{"log_time":"2024-01-01T10:05:00Z","what":"connect","operation":"connect varahf:///LA1B?freq=7103.5","success":true,"freq":{"hz":7103500,"khz":7103.5,"desc":"7.103500 MHz"},"remote_addr":"LA1B","network":"varahf"}
+{"log_time":"2024-01-01T10:06:40Z","what":"exchange","targetcall":"LA1B","success":true,"master":false,"bytes_sent":600,"bytes_received":400,"start":1704103500,"end":1704103600}
+{"log_time":"2024-01-01T11:00:00Z","what":"connect","operation":"connect varahf:///la1b?freq=3595","success":true}
+{"log_time":"2024-01-01T11:01:00Z","what":"exchange","targetcall":"LA1B","success":false,"master":false,"start":1704106800,"end":1704106860}
+{"log_time":"2024-01-01T12:00:00Z","what":"connect","operation":"accept","success":true,"remote_addr":"LA5NTA"}
+{"log_time":"2024-01-01T12:01:00Z","what":"exchange","targetcall":"LA5NTA","success":true,"master":true,"start":1704110400,"end":1704110460}
+not json
+`
+	events, err := DecodeEvents(strings.NewReader(eventLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHistory(events)
+
+	s, ok := h.Stats("LA1B", 7103500)
+	if !ok {
+		t.Fatal("missing stats for LA1B on 7103.5 kHz")
+	}
+	if s.Attempts != 2 || s.Successes != 1 || s.SuccessRate != 0.5 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+	if s.MeanThroughput != 10 {
+		t.Errorf("unexpected mean throughput: %v", s.MeanThroughput)
+	}
+	if s.LastSuccess == nil || s.LastSuccess.Hour() != 10 {
+		t.Errorf("unexpected last success: %v", s.LastSuccess)
+	}
+
+	s, ok = h.Stats("LA1B", 3595000)
+	if !ok || s.Attempts != 1 || s.Successes != 0 {
+		t.Errorf("unexpected stats for LA1B on 3595 kHz: %+v", s)
+	}
+
+	s, ok = h.GatewayStats("la1b")
+	if !ok || s.Attempts != 3 || s.Successes != 1 {
+		t.Errorf("unexpected gateway stats: %+v", s)
+	}
+
+	if _, ok := h.GatewayStats("LA5NTA"); ok {
+		t.Errorf("inbound sessions should not be recorded as gateway history")
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/la5nta/pat/internal/debug"
+	"github.com/la5nta/pat/internal/email"
+	"github.com/la5nta/pat/internal/imapd"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+const imapStateFile = ".imap.json"
+
+// imapFolders maps mailbox folders to the special-use attributes (RFC 6154) advertised to IMAP clients.
+var imapFolders = map[string]string{
+	"sent":      `\Sent`,
+	"archive":   `\Archive`,
+	TrashFolder: `\Trash`,
+}
+
+// imapBackend exposes the mailbox to IMAP clients.
+//
+// The inbox is presented as INBOX, other folders by their name. Message UIDs are assigned in
+// order of appearance and persisted in the mailbox directory, so clients can keep their caches.
+type imapBackend struct {
+	a *App
+
+	mu      sync.Mutex
+	folders map[string]*imapFolderState
+	sizes   map[string]imapSize // Rendered message size by file path.
+}
+
+type imapFolderState struct {
+	Validity uint32            `json:"validity"`
+	Next     uint32            `json:"next"`
+	UIDs     map[string]uint32 `json:"uids"` // By file name.
+
+	files map[uint32]string // File names by UID (the reverse of UIDs), built on demand.
+}
+
+// file returns the file name of the message with the given UID.
+func (s *imapFolderState) file(uid uint32) (string, bool) {
+	if s.files == nil {
+		s.files = make(map[uint32]string, len(s.UIDs))
+		for file, u := range s.UIDs {
+			s.files[u] = file
+		}
+	}
+	file, ok := s.files[uid]
+	return file, ok
+}
+
+type imapSize struct {
+	modTime time.Time
+	size    int
+}
+
+func newIMAPBackend(a *App) *imapBackend {
+	b := &imapBackend{a: a, folders: map[string]*imapFolderState{}, sizes: map[string]imapSize{}}
+	data, err := os.ReadFile(filepath.Join(a.mbox.MBoxPath, imapStateFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &b.folders); err != nil {
+			debug.Printf("Discarding corrupt IMAP state: %v", err)
+			b.folders = map[string]*imapFolderState{}
+		}
+	}
+	return b
+}
+
+func (b *imapBackend) save() {
+	data, err := json.Marshal(b.folders)
+	if err != nil {
+		debug.Printf("Unable to encode IMAP state: %v", err)
+		return
+	}
+	file := filepath.Join(b.a.mbox.MBoxPath, imapStateFile)
+	if err := os.WriteFile(file+".tmp", data, 0o644); err != nil {
+		debug.Printf("Unable to write IMAP state: %v", err)
+		return
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		debug.Printf("Unable to write IMAP state: %v", err)
+	}
+}
+
+// folderName returns the mailbox folder name of the IMAP folder.
+func (b *imapBackend) folderName(name string) string {
+	if name == "INBOX" {
+		return "in"
+	}
+	return name
+}
+
+func imapFolderError(err error) error {
+	if errors.Is(err, ErrFolderNotFound) || errors.Is(err, os.ErrNotExist) {
+		return imapd.ErrNoSuchFolder
+	}
+	return err
+}
+
+func (b *imapBackend) Authenticate(username, password string) bool {
+	expect, ok := b.a.config.IMAP.Users[username]
+	return ok && expect != "" && subtle.ConstantTimeCompare([]byte(expect), []byte(password)) == 1
+}
+
+func (b *imapBackend) Folders() ([]imapd.Folder, error) {
+	names, err := b.a.folderNames()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]imapd.Folder, 0, len(names))
+	for _, name := range names {
+		folder := imapd.Folder{Name: name}
+		switch {
+		case name == "in":
+			folder.Name = "INBOX"
+		case imapFolders[name] != "":
+			folder.Attributes = []string{imapFolders[name]}
+		}
+		list = append(list, folder)
+	}
+	return list, nil
+}
+
+func (b *imapBackend) CreateFolder(name string) error { return b.a.CreateFolder(b.folderName(name)) }
+
+func (b *imapBackend) DeleteFolder(name string) error {
+	name = b.folderName(name)
+	if err := b.a.DeleteFolder(name); err != nil {
+		return imapFolderError(err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.folders, name)
+	b.save()
+	return nil
+}
+
+func (b *imapBackend) RenameFolder(name, newName string) error {
+	name = b.folderName(name)
+	if err := b.a.RenameFolder(name, b.folderName(newName)); err != nil {
+		return imapFolderError(err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.folders, name) // The renamed folder gets a new UID validity.
+	b.save()
+	return nil
+}
+
+func (b *imapBackend) Messages(name string) (imapd.Status, []imapd.Message, error) {
+	folder := b.folderName(name)
+	msgs, _, err := b.a.IndexedMessages(folder, MailboxQuery{Asc: true})
+	if err != nil {
+		return imapd.Status{}, nil, imapFolderError(err)
+	}
+	// New messages are assigned UIDs in order of arrival.
+	sort.Slice(msgs, func(i, j int) bool {
+		if !msgs[i].ModTime.Equal(msgs[j].ModTime) {
+			return msgs[i].ModTime.Before(msgs[j].ModTime)
+		}
+		return msgs[i].MID < msgs[j].MID
+	})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.folders[folder]
+	if state == nil {
+		state = &imapFolderState{Validity: uint32(time.Now().Unix()), Next: 1}
+		b.folders[folder] = state
+	}
+	uids := make(map[string]uint32, len(msgs))
+	list := make([]imapd.Message, 0, len(msgs))
+	var changed bool
+	for _, m := range msgs {
+		file := m.MID + mailbox.Ext
+		uid, ok := state.UIDs[file]
+		if !ok {
+			uid, state.Next, changed = state.Next, state.Next+1, true
+		}
+		uids[file] = uid
+		size, err := b.size(folder, m)
+		if err != nil {
+			debug.Printf("IMAP: Unable to render %s: %v", file, err)
+			continue
+		}
+		list = append(list, imapd.Message{UID: uid, Seen: !m.Unread, Date: m.Date, Size: size})
+	}
+	if changed || len(uids) != len(state.UIDs) {
+		state.UIDs, state.files = uids, nil
+		b.save()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UID < list[j].UID })
+	return imapd.Status{UIDValidity: state.Validity, UIDNext: state.Next}, list, nil
+}
+
+// size returns the size of the rendered message. The caller must hold the lock.
+func (b *imapBackend) size(folder string, m IndexedMessage) (int, error) {
+	dir, err := b.a.folderPath(folder)
+	if err != nil {
+		return 0, err
+	}
+	file := filepath.Join(dir, m.MID+mailbox.Ext)
+	if cached, ok := b.sizes[file]; ok && cached.modTime.Equal(m.ModTime) {
+		return cached.size, nil
+	}
+	data, err := renderIMAPMessage(file)
+	if err != nil {
+		return 0, err
+	}
+	b.sizes[file] = imapSize{m.ModTime, len(data)}
+	return len(data), nil
+}
+
+// renderIMAPMessage renders the message file as an internet message.
+//
+// The unread state is exposed as the \Seen flag, so it's left out to keep the size of the message constant.
+func renderIMAPMessage(file string) ([]byte, error) {
+	msg, err := mailbox.OpenMessage(file)
+	if err != nil {
+		return nil, err
+	}
+	msg.Header.Del(email.HeaderUnread)
+	var buf bytes.Buffer
+	if err := email.Write(&buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mid returns the MID of the message with the given UID.
+func (b *imapBackend) mid(folder string, uid uint32) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if state := b.folders[folder]; state != nil {
+		if file, ok := state.file(uid); ok {
+			return strings.TrimSuffix(file, mailbox.Ext), nil
+		}
+	}
+	return "", fmt.Errorf("no message with UID %d", uid)
+}
+
+// messageFile returns the file of the message with the given UID.
+func (b *imapBackend) messageFile(folder string, uid uint32) (string, error) {
+	mid, err := b.mid(folder, uid)
+	if err != nil {
+		return "", err
+	}
+	return b.a.messageFile(folder, mid)
+}
+
+func (b *imapBackend) Fetch(name string, uid uint32) ([]byte, error) {
+	file, err := b.messageFile(b.folderName(name), uid)
+	if err != nil {
+		return nil, err
+	}
+	return renderIMAPMessage(file)
+}
+
+func (b *imapBackend) SetSeen(name string, uid uint32, seen bool) error {
+	file, err := b.messageFile(b.folderName(name), uid)
+	if err != nil {
+		return err
+	}
+	msg, err := mailbox.OpenMessage(file)
+	if err != nil {
+		return err
+	}
+	return mailbox.SetUnread(msg, !seen)
+}
+
+func (b *imapBackend) Expunge(name string, uid uint32) error {
+	folder := b.folderName(name)
+	mid, err := b.mid(folder, uid)
+	if err != nil {
+		return err
+	}
+	return b.a.DeleteMessage(mid, folder)
+}
+
+func (b *imapBackend) Copy(name string, uid uint32, dest string) error {
+	file, err := b.messageFile(b.folderName(name), uid)
+	if err != nil {
+		return err
+	}
+	dest = b.folderName(dest)
+	if dest == "out" {
+		return fmt.Errorf("messages can not be copied to the outbox")
+	}
+	dir, err := b.a.folderPath(dest)
+	if err != nil {
+		return err
+	} else if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return imapd.ErrNoSuchFolder
+	}
+	dst := filepath.Join(dir, filepath.Base(file))
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("message already exists in folder")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o664)
+}
+
+func (b *imapBackend) Move(name string, uid uint32, dest string) error {
+	folder := b.folderName(name)
+	mid, err := b.mid(folder, uid)
+	if err != nil {
+		return err
+	}
+	if dest = b.folderName(dest); dest == "out" {
+		return fmt.Errorf("messages can not be moved to the outbox")
+	}
+	return imapFolderError(b.a.MoveMessage(mid, folder, dest))
+}
+
+func (b *imapBackend) Append(name string, data []byte, seen bool) error {
+	folder := b.folderName(name)
+	if folder == "out" {
+		return fmt.Errorf("messages can not be appended to the outbox, submit them using SMTP")
+	}
+	dir, err := b.a.folderPath(folder)
+	if err != nil {
+		return err
+	} else if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return imapd.ErrNoSuchFolder
+	}
+	msg, err := email.Read(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if seen {
+		msg.Header.Del(email.HeaderUnread)
+	} else {
+		msg.Header.Set(email.HeaderUnread, "true")
+	}
+	file := filepath.Join(dir, msg.MID()+mailbox.Ext)
+	if _, err := os.Stat(file); err == nil {
+		return fmt.Errorf("message %s already exists in folder", msg.MID())
+	}
+	return writeMessageFile(file, msg)
+}
+
+// serveIMAP runs the IMAP server until the context is cancelled.
+func (a *App) serveIMAP(ctx context.Context) {
+	if len(a.config.IMAP.Users) == 0 {
+		log.Println("IMAP server not started: no users configured")
+		return
+	}
+	srv := &imapd.Server{Addr: a.config.IMAP.Addr, Backend: newIMAPBackend(a)}
+	log.Printf("IMAP server listening on %s", srv.Addr)
+	if err := srv.ListenAndServe(ctx); err != nil {
+		log.Printf("IMAP server failed: %v", err)
+	}
+}
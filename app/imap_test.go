@@ -0,0 +1,70 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/la5nta/pat/cfg"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+func TestIMAPBackend(t *testing.T) {
+	a := newTestApp(t)
+	a.config = cfg.DefaultConfig
+	a.config.IMAP.Users = map[string]string{"n0call": "secret"}
+	// UIDs are assigned in order of arrival (file modification time).
+	arrival := time.Now().Add(-time.Hour)
+	for i, msg := range addInbound(t, a, "One", "Two") {
+		file := filepath.Join(a.mbox.MBoxPath, "in", msg.MID()+mailbox.Ext)
+		if err := os.Chtimes(file, arrival, arrival.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := newIMAPBackend(a)
+	if !b.Authenticate("n0call", "secret") || b.Authenticate("n0call", "") || b.Authenticate("la5nta", "secret") {
+		t.Error("unexpected authentication result")
+	}
+	status, msgs, err := b.Messages("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].UID != 1 || msgs[1].UID != 2 || msgs[0].Seen || status.UIDNext != 3 {
+		t.Fatalf("unexpected messages: %+v %+v", status, msgs)
+	}
+	for i, subject := range []string{"One", "Two"} {
+		data, err := b.Fetch("INBOX", msgs[i].UID)
+		if err != nil || !bytes.Contains(data, []byte("Subject: "+subject+"\r\n")) || len(data) != msgs[i].Size {
+			t.Errorf("UID %d: unexpected message content (%v): %q", msgs[i].UID, err, data)
+		}
+	}
+
+	if err := b.SetSeen("INBOX", 1, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Move("INBOX", 2, "archive"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Append("INBOX", []byte("From: LA1B@winlink.org\r\nSubject: Three\r\n\r\nBody\r\n"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	// UIDs are kept across restarts.
+	b2 := newIMAPBackend(a)
+	status2, msgs, err := b2.Messages("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status2.UIDValidity != status.UIDValidity || len(msgs) != 2 || msgs[0].UID != 1 || !msgs[0].Seen || msgs[1].UID != 3 || msgs[1].Seen {
+		t.Errorf("unexpected messages: %+v %+v", status2, msgs)
+	}
+	if data, err := b2.Fetch("INBOX", 3); err != nil || !bytes.Contains(data, []byte("Subject: Three\r\n")) {
+		t.Errorf("UID 3: unexpected message content (%v): %q", err, data)
+	}
+	if err := b.Append("out", []byte("From: LA1B@winlink.org\r\n\r\n"), true); err == nil {
+		t.Error("expected append to the outbox to fail")
+	}
+}
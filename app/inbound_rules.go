@@ -0,0 +1,270 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/la5nta/pat/cfg"
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+// HeaderTags is the message header holding the (comma separated) tags of a message.
+const HeaderTags = "X-Pat-Tags"
+
+const formAttachmentPrefix = "RMS_Express_Form_"
+
+// MessageTags returns the tags of the given message.
+func MessageTags(msg *fbb.Message) []string {
+	var tags []string
+	for _, tag := range strings.Split(msg.Header.Get(HeaderTags), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// AddMessageTags adds the given tags to the message (in-memory).
+func AddMessageTags(msg *fbb.Message, tags ...string) {
+	current := MessageTags(msg)
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(current, tag) {
+			current = append(current, tag)
+		}
+	}
+	msg.Header.Set(HeaderTags, strings.Join(current, ","))
+}
+
+// FormType returns the Winlink Express form type of the message (e.g. ICS213_Initial_Viewer),
+// derived from the name of the form XML attachment.
+func FormType(msg *fbb.Message) (string, bool) {
+	for _, f := range msg.Files() {
+		name := f.Name()
+		if strings.HasPrefix(name, formAttachmentPrefix) && strings.EqualFold(filepath.Ext(name), ".xml") {
+			return strings.TrimSuffix(strings.TrimPrefix(name, formAttachmentPrefix), filepath.Ext(name)), true
+		}
+	}
+	return "", false
+}
+
+// matchInboundRule reports whether the given message matches all criteria of the rule.
+func matchInboundRule(rule cfg.InboundRule, msg *fbb.Message) (bool, error) {
+	if rule.From != "" && !matchAddress(rule.From, msg.From()) {
+		return false, nil
+	}
+	if rule.To != "" && !slices.ContainsFunc(append(msg.To(), msg.Cc()...), func(a fbb.Address) bool { return matchAddress(rule.To, a) }) {
+		return false, nil
+	}
+	if rule.Subject != "" {
+		re, err := regexp.Compile(rule.Subject)
+		if err != nil {
+			return false, fmt.Errorf("invalid subject expression: %w", err)
+		}
+		if !re.MatchString(msg.Subject()) {
+			return false, nil
+		}
+	}
+	if rule.Attachment != "" && !slices.ContainsFunc(msg.Files(), func(f *fbb.File) bool { return matchPattern(rule.Attachment, f.Name()) }) {
+		return false, nil
+	}
+	if rule.FormType != "" {
+		formType, ok := FormType(msg)
+		if !ok || !matchPattern(rule.FormType, formType) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func matchAddress(pattern string, addr fbb.Address) bool {
+	return addr.EqualString(pattern) || matchPattern(pattern, addr.Addr)
+}
+
+// matchPattern reports whether str matches the case-insensitive wildcard pattern.
+func matchPattern(pattern, str string) bool {
+	ok, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(str))
+	return ok
+}
+
+// matchedRule is an inbound rule matching a message, along with its index in the configuration.
+type matchedRule struct {
+	idx  int
+	rule cfg.InboundRule
+}
+
+// inboundAction holds the deferred actions (move, forward and command) of the rules matching a received message.
+type inboundAction struct {
+	msg   *fbb.Message
+	rules []matchedRule
+}
+
+// applyInboundRules applies the configured inbound rules to a message stored in the inbox.
+//
+// Tags and read state are applied immediately. Moves, forwards and commands are queued, to be run
+// by runInboundActions when the session is over.
+func (a *App) applyInboundRules(msg *fbb.Message) {
+	var matched []matchedRule
+	for i, rule := range a.config.InboundRules {
+		ok, err := matchInboundRule(rule, msg)
+		if err != nil {
+			log.Printf("Inbound rule %s: %v", ruleName(i, rule), err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		matched = append(matched, matchedRule{i, rule})
+		if rule.Stop {
+			break
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	file := filepath.Join(a.mbox.MBoxPath, mailbox.DIR_INBOX, msg.MID()+mailbox.Ext)
+	var dirty, markRead, deferred bool
+	for _, m := range matched {
+		if len(m.rule.Tags) > 0 {
+			AddMessageTags(msg, m.rule.Tags...)
+			dirty = true
+		}
+		markRead = markRead || m.rule.MarkRead
+		deferred = deferred || m.rule.Move != "" || len(m.rule.Forward) > 0 || m.rule.Command != ""
+	}
+	var err error
+	switch {
+	case markRead && mailbox.IsUnread(msg):
+		msg.Header.Set("X-FilePath", file)
+		err = mailbox.SetUnread(msg, false) // Writes the tags as well
+		msg.Header.Del("X-FilePath")
+	case dirty:
+		err = writeMessageFile(file, msg)
+	}
+	if err != nil {
+		log.Printf("Unable to update message %s: %v", msg.MID(), err)
+	}
+	if deferred {
+		a.inboundMu.Lock()
+		a.inboundActions = append(a.inboundActions, inboundAction{msg, matched})
+		a.inboundMu.Unlock()
+	}
+}
+
+// runInboundActions runs the queued inbound rule actions.
+func (a *App) runInboundActions() {
+	a.inboundMu.Lock()
+	actions := a.inboundActions
+	a.inboundActions = nil
+	a.inboundMu.Unlock()
+	for _, action := range actions {
+		a.runInboundAction(action)
+	}
+}
+
+func (a *App) runInboundAction(action inboundAction) {
+	msg := action.msg
+	file := filepath.Join(a.mbox.MBoxPath, mailbox.DIR_INBOX, msg.MID()+mailbox.Ext)
+	for _, m := range action.rules {
+		if m.rule.Move != "" {
+			moved, err := a.moveMessageFile(file, m.rule.Move)
+			if err != nil {
+				log.Printf("Inbound rule %s: unable to move message %s: %v", ruleName(m.idx, m.rule), msg.MID(), err)
+			} else {
+				file = moved
+			}
+		}
+		if len(m.rule.Forward) > 0 {
+			if err := a.forwardMessage(msg, m.rule.Forward...); err != nil {
+				log.Printf("Inbound rule %s: unable to forward message %s: %v", ruleName(m.idx, m.rule), msg.MID(), err)
+			}
+		}
+		if m.rule.Command != "" {
+			if err := a.runRuleCommand(m.rule, file, msg); err != nil {
+				log.Printf("Inbound rule %s: command failed for message %s: %v", ruleName(m.idx, m.rule), msg.MID(), err)
+			}
+		}
+	}
+}
+
+func ruleName(idx int, rule cfg.InboundRule) string {
+	if rule.Name != "" {
+		return fmt.Sprintf("'%s'", rule.Name)
+	}
+	return fmt.Sprintf("#%d", idx+1)
+}
+
+func writeMessageFile(file string, msg *fbb.Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o664)
+}
+
+// moveMessageFile moves the message file to the given mailbox folder, returning the new path.
+func (a *App) moveMessageFile(file, folder string) (string, error) {
+	dir, err := a.folderPath(folder)
+	if err != nil {
+		return "", err
+	}
+	if dir == filepath.Join(a.mbox.MBoxPath, mailbox.DIR_OUTBOX) {
+		return "", fmt.Errorf("messages can not be moved to the outbox")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, filepath.Base(file))
+	return dst, os.Rename(file, dst)
+}
+
+// forwardMessage posts a copy of the message (including attachments) to the outbox, addressed to the given recipients.
+func (a *App) forwardMessage(msg *fbb.Message, to ...string) error {
+	fwd := fbb.NewMessage(fbb.Private, a.options.MyCall)
+	fwd.AddTo(to...)
+	fwd.SetSubject("Fwd: " + strings.TrimSpace(strings.TrimPrefix(msg.Subject(), "Fwd:")))
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "--- %s %s wrote: ---\n", msg.Date(), msg.From().Addr)
+	original, _ := msg.Body()
+	for _, line := range strings.Split(strings.TrimRight(original, "\r\n"), "\n") {
+		fmt.Fprintf(&body, ">%s\n", strings.TrimRight(line, "\r"))
+	}
+	if err := fwd.SetBody(body.String()); err != nil {
+		return err
+	}
+	for _, f := range msg.Files() {
+		fwd.AddFile(f)
+	}
+	return a.mbox.AddOut(fwd)
+}
+
+func (a *App) runRuleCommand(rule cfg.InboundRule, file string, msg *fbb.Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, rule.Command, rule.Args...)
+	cmd.Env = append(append(os.Environ(), a.Env()...), notificationEnv(NotificationEvent{Event: NotifyMessage, Message: NewMessageSummary(msg)})...)
+	cmd.Env = append(cmd.Env, "PAT_MESSAGE_PATH="+file)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = log.Writer()
+	return cmd.Run()
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/la5nta/pat/cfg"
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+func TestMatchInboundRule(t *testing.T) {
+	msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+	msg.AddTo("EOC-1")
+	msg.AddCc("foo@example.com")
+	msg.SetSubject("ICS-213 Resource request")
+	msg.AddFile(fbb.NewFile("RMS_Express_Form_ICS213_Initial_Viewer.xml", []byte("<xml/>")))
+
+	tests := []struct {
+		rule  cfg.InboundRule
+		match bool
+	}{
+		{cfg.InboundRule{}, true},
+		{cfg.InboundRule{From: "la5nta"}, true},
+		{cfg.InboundRule{From: "LA1B"}, false},
+		{cfg.InboundRule{To: "EOC-*"}, true},
+		{cfg.InboundRule{To: "*@example.com"}, true},
+		{cfg.InboundRule{To: "EOC-2"}, false},
+		{cfg.InboundRule{Subject: "^ICS-213"}, true},
+		{cfg.InboundRule{Subject: "^Re:"}, false},
+		{cfg.InboundRule{Attachment: "*.XML"}, true},
+		{cfg.InboundRule{Attachment: "*.jpg"}, false},
+		{cfg.InboundRule{FormType: "ics213*"}, true},
+		{cfg.InboundRule{FormType: "ICS213", From: "LA5NTA"}, false},
+	}
+	for _, tt := range tests {
+		got, err := matchInboundRule(tt.rule, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.match {
+			t.Errorf("%+v: expected match=%t", tt.rule, tt.match)
+		}
+	}
+}
+
+func TestApplyInboundRules(t *testing.T) {
+	a := newTestApp(t)
+	a.config.InboundRules = []cfg.InboundRule{
+		{To: "EOC-1", Tags: []string{"eoc"}, MarkRead: true, Move: "eoc", Forward: []string{"LA1B"}, Stop: true},
+		{Move: "archive"},
+	}
+
+	msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+	msg.AddTo("EOC-1")
+	msg.SetSubject("Status")
+	msg.SetBody("All good")
+	if err := a.mbox.ProcessInbound(msg); err != nil {
+		t.Fatal(err)
+	}
+	a.applyInboundRules(msg)
+	if _, err := os.Stat(filepath.Join(a.mbox.MBoxPath, "in", msg.MID()+mailbox.Ext)); err != nil {
+		t.Errorf("expected move to be deferred: %v", err)
+	}
+	a.runInboundActions()
+
+	moved, err := mailbox.OpenMessage(filepath.Join(a.mbox.MBoxPath, "eoc", msg.MID()+mailbox.Ext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mailbox.IsUnread(moved) {
+		t.Error("expected message to be marked as read")
+	}
+	if tags := MessageTags(moved); len(tags) != 1 || tags[0] != "eoc" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+	if _, err := os.Stat(filepath.Join(a.mbox.MBoxPath, "in", msg.MID()+mailbox.Ext)); !os.IsNotExist(err) {
+		t.Errorf("expected message to be moved from inbox: %v", err)
+	}
+	out, err := a.mbox.Outbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Subject() != "Fwd: Status" || !out[0].To()[0].EqualString("LA1B") {
+		t.Errorf("unexpected outbox: %v", out)
+	}
+}
+
+func TestInboundRuleLogIndex(t *testing.T) {
+	a := newTestApp(t)
+	a.config.InboundRules = []cfg.InboundRule{{From: "LA1B", Move: "archive"}, {Move: "out"}}
+
+	msg := addInbound(t, a, "Status")[0]
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	a.applyInboundRules(msg)
+	a.runInboundActions()
+	if !strings.Contains(buf.String(), "Inbound rule #2: unable to move") {
+		t.Errorf("expected failure to be logged for rule #2, got %q", buf.String())
+	}
+}
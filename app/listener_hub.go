@@ -129,7 +129,7 @@ func (l *Listener) acceptLoop() error {
 
 		freq, _ := l.t.CurrentFreq()
 
-		l.eventLog.LogConn("accept", freq, conn, nil)
+		l.eventLog.LogConn("accept "+l.t.Name(), freq, conn, nil)
 		log.Printf("Got connect (%s:%s)", l.t.Name(), remoteCall)
 
 		err = l.exchange(conn, remoteCall, true)
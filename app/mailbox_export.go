@@ -0,0 +1,342 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/la5nta/pat/internal/email"
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+// Mailbox export and import formats.
+const (
+	FormatEML     = "eml"     // A directory of RFC 5322 message files.
+	FormatMbox    = "mbox"    // A single mbox (mboxrd) file.
+	FormatMaildir = "maildir" // A Maildir directory.
+	FormatExpress = "express" // Winlink Express message folders (MID.mime files).
+)
+
+// ExpressFolders maps the built-in mailbox folders to the corresponding Winlink Express folder names.
+// Custom folders keep their name.
+var ExpressFolders = map[string]string{
+	"in":        "Inbox",
+	"out":       "Outbox",
+	"sent":      "Sent Items",
+	"archive":   "Saved Items",
+	TrashFolder: "Deleted Items",
+}
+
+// expressFolder returns the Winlink Express folder name of the given mailbox folder.
+func expressFolder(folder string) string {
+	if name, ok := ExpressFolders[folder]; ok {
+		return name
+	}
+	return folder
+}
+
+// folderFromExpress returns the mailbox folder name of the given Winlink Express folder.
+func folderFromExpress(name string) string {
+	for folder, expressName := range ExpressFolders {
+		if strings.EqualFold(name, expressName) {
+			return folder
+		}
+	}
+	return name
+}
+
+// ExportMailbox writes the messages in the given folders as internet messages to the directory dst.
+//
+// If no folders are given, all folders except the trash are exported. Each folder is written as
+// dst/FOLDER/MID.eml (eml), dst/FOLDER.mbox (mbox), the Maildir dst/FOLDER (maildir) or
+// dst/EXPRESS_FOLDER/MID.mime (express, see ExpressFolders). The destination must not exist or
+// be empty. Returns the number of exported messages.
+func (a *App) ExportMailbox(format, dst string, folders ...string) (int, error) {
+	if !slices.Contains([]string{FormatEML, FormatMbox, FormatMaildir, FormatExpress}, format) {
+		return 0, fmt.Errorf("unsupported format '%s'", format)
+	}
+	if entries, err := os.ReadDir(dst); err == nil && len(entries) > 0 {
+		return 0, fmt.Errorf("destination '%s' is not empty", dst)
+	}
+	if len(folders) == 0 {
+		all, err := a.folderNames()
+		if err != nil {
+			return 0, err
+		}
+		for _, name := range all {
+			if name != TrashFolder {
+				folders = append(folders, name)
+			}
+		}
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, folder := range folders {
+		msgs, err := a.FolderMessages(folder)
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", folder, err)
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+		sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Date().Before(msgs[j].Date()) })
+		path := filepath.Join(dst, folder)
+		if format == FormatExpress {
+			path = filepath.Join(dst, expressFolder(folder))
+		}
+		exported, err := exportFolder(format, path, msgs)
+		n += exported
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", folder, err)
+		}
+	}
+	return n, nil
+}
+
+func exportFolder(format, path string, msgs []*fbb.Message) (int, error) {
+	switch format {
+	case FormatEML, FormatExpress:
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			return 0, err
+		}
+		for i, msg := range msgs {
+			if err := writeMessageAs(format, path, msg); err != nil {
+				return i, err
+			}
+		}
+	case FormatMbox:
+		f, err := os.Create(path + ".mbox")
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		for i, msg := range msgs {
+			if err := email.WriteMbox(f, msg); err != nil {
+				return i, err
+			}
+		}
+		return len(msgs), f.Close()
+	case FormatMaildir:
+		for i, msg := range msgs {
+			if err := email.WriteMaildir(path, msg); err != nil {
+				return i, err
+			}
+		}
+	}
+	return len(msgs), nil
+}
+
+// writeMessageAs writes the message as MID.eml or MID.mime (express) in the given directory.
+func writeMessageAs(format, dir string, msg *fbb.Message) error {
+	write, ext := email.Write, ".eml"
+	if format == FormatExpress {
+		write, ext = email.WriteExpress, ".mime"
+	}
+	f, err := os.Create(filepath.Join(dir, msg.MID()+ext))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := write(f, msg); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// DetectMailboxFormat guesses the export format of the file or directory at path.
+func DetectMailboxFormat(path string) (string, error) {
+	fi, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return "", err
+	case fi.IsDir() && email.IsMaildir(path):
+		return FormatMaildir, nil
+	case fi.IsDir() && isExpressDir(path), strings.EqualFold(filepath.Ext(path), ".mime"):
+		return FormatExpress, nil
+	case fi.IsDir(), strings.EqualFold(filepath.Ext(path), ".eml"):
+		return FormatEML, nil
+	default:
+		return FormatMbox, nil
+	}
+}
+
+// ImportMailbox converts the internet messages at src to Winlink messages in the given folder.
+//
+// The source is a single .eml file or a directory of .eml files (eml), an mbox file (mbox), a
+// Maildir (maildir), or a single .mime file or a directory of .mime files (express). If format is
+// empty, it is detected from the source. Messages already present in the folder (by MID) are
+// skipped. Returns the number of imported and skipped messages.
+//
+// If src is a Winlink Express messages directory (with folders like Inbox and Sent Items) and
+// folder is empty, the messages of each Winlink Express folder are imported to the corresponding
+// mailbox folder (see ExpressFolders), including pending messages in the outbox. Otherwise, an
+// empty folder defaults to the inbox.
+func (a *App) ImportMailbox(format, src, folder string) (imported, skipped int, err error) {
+	if format == "" {
+		if format, err = DetectMailboxFormat(src); err != nil {
+			return 0, 0, err
+		}
+	}
+	imp := &mailboxImporter{a: a}
+	if format == FormatExpress && folder == "" {
+		if folders, _ := expressSubfolders(src); len(folders) > 0 {
+			for _, name := range folders {
+				add, err := imp.into(folderFromExpress(name), true)
+				if err != nil {
+					return imp.imported, imp.skipped, err
+				}
+				if err := importFiles(filepath.Join(src, name), ".mime", add); err != nil {
+					return imp.imported, imp.skipped, err
+				}
+			}
+			return imp.imported, imp.skipped, nil
+		}
+	}
+	if folder == "" {
+		folder = "in"
+	}
+	add, err := imp.into(folder, false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch format {
+	case FormatEML:
+		err = importFiles(src, ".eml", add)
+	case FormatExpress:
+		err = importFiles(src, ".mime", add)
+	case FormatMbox:
+		var f *os.File
+		if f, err = os.Open(src); err != nil {
+			break
+		}
+		defer f.Close()
+		err = email.ReadMbox(f, add)
+	case FormatMaildir:
+		err = email.ReadMaildir(src, add)
+	default:
+		err = fmt.Errorf("unsupported format '%s'", format)
+	}
+	return imp.imported, imp.skipped, err
+}
+
+type mailboxImporter struct {
+	a                 *App
+	imported, skipped int
+}
+
+// into returns a function adding messages to the given folder.
+//
+// When migrating a mailbox, missing folders are created and messages may be imported to the outbox.
+func (imp *mailboxImporter) into(folder string, migrate bool) (func(*fbb.Message) error, error) {
+	if folder == "out" && !migrate {
+		return nil, fmt.Errorf("messages can not be imported to the outbox")
+	}
+	dir, err := imp.a.folderPath(folder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) && !migrate && !slices.Contains(BuiltinFolders, folder) {
+		return nil, ErrFolderNotFound
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return func(msg *fbb.Message) error {
+		file := filepath.Join(dir, msg.MID()+mailbox.Ext)
+		if filepath.Dir(file) != dir {
+			return fmt.Errorf("invalid message id '%s'", msg.MID())
+		}
+		if _, err := os.Stat(file); err == nil {
+			imp.skipped++
+			return nil
+		}
+		if folder == "out" {
+			msg.Header.Del(email.HeaderUnread)
+		}
+		if err := writeMessageFile(file, msg); err != nil {
+			return err
+		}
+		imp.imported++
+		return nil
+	}, nil
+}
+
+// isExpressDir reports whether dir contains Winlink Express message files or folders.
+func isExpressDir(dir string) bool {
+	if folders, _ := expressSubfolders(dir); len(folders) > 0 {
+		return true
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.mime"))
+	return len(matches) > 0
+}
+
+// expressSubfolders returns the names of the Winlink Express message folders in dir.
+//
+// Dir is considered a Winlink Express messages directory if it contains at least one of the
+// known folders (see ExpressFolders), in which case all subdirectories are returned.
+func expressSubfolders(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var folders []string
+	var known bool
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		folders = append(folders, e.Name())
+		if _, ok := ExpressFolders[folderFromExpress(e.Name())]; ok {
+			known = true
+		}
+	}
+	if !known {
+		return nil, nil
+	}
+	return folders, nil
+}
+
+// importFiles reads the message file at src, or all message files with the given extension in the directory src.
+func importFiles(src, ext string, fn func(*fbb.Message) error) error {
+	files := []string{src}
+	if fi, err := os.Stat(src); err != nil {
+		return err
+	} else if fi.IsDir() {
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		files = files[:0]
+		for _, e := range entries {
+			if e.Type().IsRegular() && strings.EqualFold(filepath.Ext(e.Name()), ext) {
+				files = append(files, filepath.Join(src, e.Name()))
+			}
+		}
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		msg, err := email.Read(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+func TestExportImportMailbox(t *testing.T) {
+	a := newTestApp(t)
+	var mids []string
+	for _, subject := range []string{"Situation report", "Resource request"} {
+		msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+		msg.AddTo("N0CALL")
+		msg.SetSubject(subject)
+		msg.SetBody("Body")
+		msg.AddFile(fbb.NewFile("map.png", []byte{0x89, 'P', 'N', 'G'}))
+		if err := a.mbox.ProcessInbound(msg); err != nil {
+			t.Fatal(err)
+		}
+		mids = append(mids, msg.MID())
+	}
+	if err := a.MoveMessage(mids[1], "in", "archive"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{FormatEML, FormatMbox, FormatMaildir} {
+		t.Run(format, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "export")
+			n, err := a.ExportMailbox(format, dst, "in", "archive")
+			if err != nil || n != 2 {
+				t.Fatalf("expected 2 exported messages, got %d (%v)", n, err)
+			}
+			if _, err := a.ExportMailbox(format, dst); err == nil {
+				t.Error("expected export to non-empty destination to fail")
+			}
+
+			src := filepath.Join(dst, "in")
+			if format == FormatMbox {
+				src += ".mbox"
+			}
+			if detected, _ := DetectMailboxFormat(src); detected != format {
+				t.Errorf("expected detected format %s, got %s", format, detected)
+			}
+			folder := "import-" + format
+			if err := a.CreateFolder(folder); err != nil {
+				t.Fatal(err)
+			}
+			imported, skipped, err := a.ImportMailbox("", src, folder)
+			if err != nil || imported != 1 || skipped != 0 {
+				t.Fatalf("unexpected import result: %d imported, %d skipped (%v)", imported, skipped, err)
+			}
+			if _, skipped, _ = a.ImportMailbox(format, src, folder); skipped != 1 {
+				t.Errorf("expected duplicate to be skipped, got %d", skipped)
+			}
+			file, err := a.messageFile(folder, mids[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := mailbox.OpenMessage(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Subject() != "Situation report" || len(msg.Files()) != 1 || !mailbox.IsUnread(msg) {
+				t.Errorf("unexpected imported message: %v", msg.Header)
+			}
+		})
+	}
+
+	dst := filepath.Join(t.TempDir(), "export")
+	if _, err := a.ExportMailbox(FormatEML, dst, "in"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.ImportMailbox(FormatEML, filepath.Join(dst, "in"), "out"); err == nil || !strings.Contains(err.Error(), "outbox") {
+		t.Errorf("expected outbox import error, got %v", err)
+	}
+	if msgs, _ := a.mbox.Outbox(); len(msgs) != 0 {
+		t.Errorf("expected empty outbox, got %d message(s)", len(msgs))
+	}
+}
+
+func TestExpressMailbox(t *testing.T) {
+	src := newTestApp(t)
+	form := fbb.NewMessage(fbb.Private, "LA5NTA")
+	form.AddTo("N0CALL")
+	form.SetSubject("ICS-213 General message")
+	form.SetBody("Form body")
+	form.AddFile(fbb.NewFile("RMS_Express_Form_ICS213_Initial_Viewer.xml", []byte(`<?xml version="1.0"?><RMS_Express_Form/>`)))
+	pending := fbb.NewMessage(fbb.Private, "N0CALL")
+	pending.AddTo("LA5NTA")
+	pending.SetSubject("Pending")
+	pending.SetBody("Not sent yet")
+	if err := src.mbox.ProcessInbound(form); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.mbox.AddOut(pending); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.CreateFolder("eoc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.MoveMessage(form.MID(), "in", "eoc"); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	if n, err := src.ExportMailbox(FormatExpress, dst); err != nil || n != 2 {
+		t.Fatalf("expected 2 exported messages, got %d (%v)", n, err)
+	}
+	for _, file := range []string{filepath.Join("Outbox", pending.MID()+".mime"), filepath.Join("eoc", form.MID()+".mime")} {
+		if _, err := os.Stat(filepath.Join(dst, file)); err != nil {
+			t.Errorf("expected %s to be exported: %v", file, err)
+		}
+	}
+
+	b := newTestApp(t)
+	if format, _ := DetectMailboxFormat(dst); format != FormatExpress {
+		t.Errorf("expected express format to be detected, got %s", format)
+	}
+	if imported, _, err := b.ImportMailbox("", dst, ""); err != nil || imported != 2 {
+		t.Fatalf("expected 2 imported messages, got %d (%v)", imported, err)
+	}
+	if file, err := b.messageFile("out", pending.MID()); err != nil {
+		t.Errorf("expected pending message in the outbox: %v", err)
+	} else if msg, err := mailbox.OpenMessage(file); err != nil || mailbox.IsUnread(msg) {
+		t.Errorf("expected pending message to be read (%v)", err)
+	}
+	file, err := b.messageFile("eoc", form.MID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mailbox.OpenMessage(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := FormType(msg); !ok || !mailbox.IsUnread(msg) || !msg.Date().Equal(form.Date()) {
+		t.Errorf("unexpected imported form message: %v", msg.Header)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/la5nta/pat/internal/directories"
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+var (
+	ErrFolderNotFound = errors.New("folder not found")
+	ErrFolderExists   = errors.New("folder already exists")
+	ErrFolderNotEmpty = errors.New("folder not empty")
+	ErrFolderBuiltin  = errors.New("built-in folders can not be modified")
+)
+
+// BuiltinFolders are the mailbox folders that can not be renamed or deleted.
+var BuiltinFolders = []string{"in", "out", "sent", "archive", TrashFolder}
+
+// Folder describes a mailbox folder.
+type Folder struct {
+	Name     string `json:"name"`
+	Builtin  bool   `json:"builtin"`
+	Messages int    `json:"messages"`
+	Unread   int    `json:"unread"`
+}
+
+// folderPath returns the path of the given mailbox folder.
+//
+// Folder names are single path elements within the mailbox path. Names starting with a dot are reserved.
+func (a *App) folderPath(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid folder name '%s'", name)
+	}
+	dir := filepath.Join(a.mbox.MBoxPath, name)
+	if !directories.IsInPath(a.mbox.MBoxPath, dir) || filepath.Clean(dir) == filepath.Clean(a.mbox.MBoxPath) {
+		return "", fmt.Errorf("invalid folder name '%s'", name)
+	}
+	return dir, nil
+}
+
+// Folders returns the folders of the mailbox, built-in folders first.
+//
+// Message counts are taken from the mailbox index.
+func (a *App) Folders() ([]Folder, error) {
+	names, err := a.folderNames()
+	if err != nil {
+		return nil, err
+	}
+	folders := make([]Folder, 0, len(names))
+	for _, name := range names {
+		f := Folder{Name: name, Builtin: slices.Contains(BuiltinFolders, name)}
+		msgs, _, err := a.IndexedMessages(name, MailboxQuery{})
+		if err != nil {
+			return nil, err
+		}
+		f.Messages = len(msgs)
+		for _, m := range msgs {
+			if m.Unread {
+				f.Unread++
+			}
+		}
+		folders = append(folders, f)
+	}
+	return folders, nil
+}
+
+// folderNames returns the names of the mailbox folders, built-in folders first.
+func (a *App) folderNames() ([]string, error) {
+	entries, err := os.ReadDir(a.mbox.MBoxPath)
+	if err != nil {
+		return nil, err
+	}
+	var custom []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") && !slices.Contains(BuiltinFolders, e.Name()) {
+			custom = append(custom, e.Name())
+		}
+	}
+	sort.Strings(custom)
+	return append(slices.Clone(BuiltinFolders), custom...), nil
+}
+
+// FolderMessages returns all messages in the given mailbox folder.
+func (a *App) FolderMessages(name string) ([]*fbb.Message, error) {
+	dir, err := a.folderPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) && slices.Contains(BuiltinFolders, name) {
+		return nil, nil // Not created yet (e.g. trash)
+	} else if os.IsNotExist(err) {
+		return nil, ErrFolderNotFound
+	}
+	return mailbox.LoadMessageDir(dir)
+}
+
+// CreateFolder creates a new mailbox folder.
+func (a *App) CreateFolder(name string) error {
+	dir, err := a.folderPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Mkdir(dir, 0o755)
+	if os.IsExist(err) {
+		return ErrFolderExists
+	}
+	return err
+}
+
+// RenameFolder renames a custom mailbox folder.
+func (a *App) RenameFolder(name, newName string) error {
+	if slices.Contains(BuiltinFolders, name) || slices.Contains(BuiltinFolders, newName) {
+		return ErrFolderBuiltin
+	}
+	src, err := a.folderPath(name)
+	if err != nil {
+		return err
+	}
+	dst, err := a.folderPath(newName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return ErrFolderNotFound
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return ErrFolderExists
+	}
+	return os.Rename(src, dst)
+}
+
+// DeleteFolder deletes an empty custom mailbox folder.
+func (a *App) DeleteFolder(name string) error {
+	if slices.Contains(BuiltinFolders, name) {
+		return ErrFolderBuiltin
+	}
+	dir, err := a.folderPath(name)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	switch {
+	case os.IsNotExist(err):
+		return ErrFolderNotFound
+	case err != nil:
+		return err
+	case len(entries) > 0:
+		return ErrFolderNotEmpty
+	}
+	return os.Remove(dir)
+}
+
+// MoveMessage moves the message with the given MID from one mailbox folder to another.
+func (a *App) MoveMessage(mid, from, to string) error {
+	file, err := a.messageFile(from, mid)
+	if err != nil {
+		return err
+	}
+	if dst, err := a.folderPath(to); err != nil {
+		return err
+	} else if _, err := os.Stat(dst); os.IsNotExist(err) {
+		return ErrFolderNotFound
+	}
+	_, err = a.moveMessageFile(file, to)
+	return err
+}
+
+// messageFile returns the path of the message with the given MID in the given mailbox folder.
+func (a *App) messageFile(folder, mid string) (string, error) {
+	dir, err := a.folderPath(folder)
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, mid+mailbox.Ext)
+	if filepath.Dir(file) != dir {
+		return "", fmt.Errorf("invalid message id '%s'", mid)
+	}
+	if _, err := os.Stat(file); err != nil {
+		return "", err
+	}
+	return file, nil
+}
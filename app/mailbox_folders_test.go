@@ -0,0 +1,66 @@
+package app
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMailboxFolders(t *testing.T) {
+	a := newTestApp(t)
+
+	for _, name := range []string{"", ".index", "../in", "eoc/ops", ".."} {
+		if err := a.CreateFolder(name); err == nil {
+			t.Errorf("expected error for folder name %q", name)
+		}
+	}
+	if err := a.CreateFolder("eoc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreateFolder("eoc"); !errors.Is(err, ErrFolderExists) {
+		t.Errorf("expected ErrFolderExists, got %v", err)
+	}
+	if err := a.RenameFolder("in", "inbox"); !errors.Is(err, ErrFolderBuiltin) {
+		t.Errorf("expected ErrFolderBuiltin, got %v", err)
+	}
+
+	msg := addInbound(t, a, "Status")[0]
+	if err := a.MoveMessage(msg.MID(), "in", "ops"); !errors.Is(err, ErrFolderNotFound) {
+		t.Errorf("expected ErrFolderNotFound, got %v", err)
+	}
+	if err := a.MoveMessage(msg.MID(), "in", "out"); err == nil {
+		t.Error("expected error when moving to the outbox")
+	}
+	if err := a.MoveMessage("../"+msg.MID(), "in", "eoc"); err == nil {
+		t.Error("expected error for invalid MID")
+	}
+	if err := a.MoveMessage(msg.MID(), "in", "eoc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeleteFolder("eoc"); !errors.Is(err, ErrFolderNotEmpty) {
+		t.Errorf("expected ErrFolderNotEmpty, got %v", err)
+	}
+	if err := a.RenameFolder("eoc", "ops"); err != nil {
+		t.Fatal(err)
+	}
+
+	folders, err := a.Folders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folders) != 6 || folders[5].Name != "ops" || folders[5].Builtin || folders[5].Messages != 1 || folders[5].Unread != 1 {
+		t.Errorf("unexpected folders: %+v", folders)
+	}
+	if folders[0].Name != "in" || folders[0].Messages != 0 {
+		t.Errorf("unexpected inbox: %+v", folders[0])
+	}
+
+	if err := a.MoveMessage(msg.MID(), "ops", "archive"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeleteFolder("ops"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.FolderMessages("ops"); !errors.Is(err, ErrFolderNotFound) {
+		t.Errorf("expected ErrFolderNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/la5nta/pat/internal/debug"
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+// mailboxIndexFile is the name of the index file, relative to the mailbox path.
+//
+// The name is dot-prefixed so that it's ignored as a folder and by the mailbox watcher.
+const mailboxIndexFile = ".index.json"
+
+// mailboxIndexVersion must be bumped whenever IndexedMessage changes, to force a rebuild of existing indexes.
+const mailboxIndexVersion = 3
+
+// IndexedMessage is the metadata of a message in the mailbox index.
+//
+// The field names match the JSON representation of messages in the HTTP API.
+type IndexedMessage struct {
+	MID     string
+	Date    time.Time
+	From    fbb.Address
+	To      []fbb.Address
+	Cc      []fbb.Address
+	Subject string
+	Files   []IndexedFile
+	P2POnly bool
+	Unread  bool
+
+	InReplyTo  string   `json:",omitempty"` // MID of the message this is a reply to (if known).
+	References []string `json:",omitempty"` // MIDs of previous messages in the conversation (if known).
+
+	Size    int64     // Size of the message file.
+	ModTime time.Time // Modification time of the message file.
+
+	Outbox *OutboxStatus `json:",omitempty"` // Sending metadata and state (outbox listings only).
+}
+
+// OutboxStatus is the sending metadata and current state of an outbox message.
+type OutboxStatus struct {
+	OutboxMeta
+	State string `json:"state"`
+}
+
+// IndexedFile is the metadata of a message attachment.
+type IndexedFile struct {
+	Name string
+	Size int
+}
+
+// NewIndexedMessage returns the index metadata of the given message.
+func NewIndexedMessage(msg *fbb.Message) IndexedMessage {
+	m := IndexedMessage{
+		MID:     msg.MID(),
+		Date:    msg.Date(),
+		From:    msg.From(),
+		To:      msg.To(),
+		Cc:      msg.Cc(),
+		Subject: msg.Subject(),
+		P2POnly: msg.Header.Get("X-P2POnly") == "true",
+		Unread:  mailbox.IsUnread(msg),
+
+		InReplyTo:  InReplyTo(msg),
+		References: References(msg),
+	}
+	if meta := GetOutboxMeta(msg); meta != (OutboxMeta{}) {
+		m.Outbox = &OutboxStatus{OutboxMeta: meta}
+	}
+	for _, f := range msg.Files() {
+		m.Files = append(m.Files, IndexedFile{f.Name(), f.Size()})
+	}
+	return m
+}
+
+// MailboxIndex is a persistent cache of message metadata, keyed by folder and file name.
+//
+// Entries are validated against the size and modification time of the message files
+// whenever a folder is listed, so the index is never stale even if filesystem events are missed.
+type MailboxIndex struct {
+	root string
+
+	mu      sync.Mutex
+	folders map[string]map[string]IndexedMessage
+	dirty   bool
+}
+
+// OpenMailboxIndex loads the index of the mailbox at the given path.
+//
+// A missing or incompatible index file yields an empty index, which is rebuilt on demand.
+func OpenMailboxIndex(root string) *MailboxIndex {
+	x := &MailboxIndex{root: root, folders: map[string]map[string]IndexedMessage{}}
+	data, err := os.ReadFile(filepath.Join(root, mailboxIndexFile))
+	if err != nil {
+		return x
+	}
+	var stored struct {
+		Version int
+		Folders map[string]map[string]IndexedMessage
+	}
+	switch err := json.Unmarshal(data, &stored); {
+	case err != nil:
+		debug.Printf("Discarding corrupt mailbox index: %v", err)
+	case stored.Version != mailboxIndexVersion:
+		debug.Printf("Discarding mailbox index version %d", stored.Version)
+	case stored.Folders != nil:
+		x.folders = stored.Folders
+	}
+	return x
+}
+
+// Messages returns the indexed metadata of all messages in the given folder, re-indexing changed files.
+func (x *MailboxIndex) Messages(folder string) ([]IndexedMessage, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(x.root, folder))
+	if err != nil {
+		return nil, err
+	}
+	current := x.folders[folder]
+	next := make(map[string]IndexedMessage, len(entries))
+	msgs := make([]IndexedMessage, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != mailbox.Ext {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue // Removed while listing
+		}
+		m, ok := current[e.Name()]
+		if !ok || m.Size != fi.Size() || !m.ModTime.Equal(fi.ModTime()) {
+			if m, err = indexMessageFile(filepath.Join(x.root, folder, e.Name()), fi); err != nil {
+				debug.Printf("Unable to index %s: %v", e.Name(), err)
+				continue
+			}
+			x.dirty = true
+		}
+		next[e.Name()] = m
+		msgs = append(msgs, m)
+	}
+	if len(next) != len(current) {
+		x.dirty = true
+	}
+	x.folders[folder] = next
+	x.save()
+	return msgs, nil
+}
+
+// Update re-indexes the given message file (e.g. on a filesystem event).
+func (x *MailboxIndex) Update(path string) {
+	rel, err := filepath.Rel(x.root, path)
+	if err != nil || filepath.Ext(path) != mailbox.Ext {
+		return
+	}
+	folder, name := filepath.Split(rel)
+	folder = filepath.Clean(folder)
+	if folder == "." || strings.ContainsRune(folder, filepath.Separator) || strings.HasPrefix(folder, ".") {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	entries := x.folders[folder]
+	if entries == nil {
+		return // Folder not listed yet, indexed on demand.
+	}
+	fi, err := os.Stat(path)
+	if err == nil {
+		var m IndexedMessage
+		if m, err = indexMessageFile(path, fi); err == nil {
+			entries[name] = m
+		}
+	}
+	if err != nil {
+		delete(entries, name) // Removed, or not completely written yet.
+	}
+	x.dirty = true
+	x.save()
+}
+
+func (x *MailboxIndex) save() {
+	if !x.dirty {
+		return
+	}
+	data, err := json.Marshal(struct {
+		Version int
+		Folders map[string]map[string]IndexedMessage
+	}{mailboxIndexVersion, x.folders})
+	if err != nil {
+		debug.Printf("Unable to encode mailbox index: %v", err)
+		return
+	}
+	file := filepath.Join(x.root, mailboxIndexFile)
+	if err := os.WriteFile(file+".tmp", data, 0o644); err != nil {
+		debug.Printf("Unable to write mailbox index: %v", err)
+		return
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		debug.Printf("Unable to write mailbox index: %v", err)
+		return
+	}
+	x.dirty = false
+}
+
+func indexMessageFile(path string, fi os.FileInfo) (IndexedMessage, error) {
+	msg, err := mailbox.OpenMessage(path)
+	if err != nil {
+		return IndexedMessage{}, err
+	}
+	m := NewIndexedMessage(msg)
+	m.Size, m.ModTime = fi.Size(), fi.ModTime()
+	return m, nil
+}
+
+// MailboxQuery holds the sorting, filtering and pagination parameters of a mailbox listing.
+type MailboxQuery struct {
+	Sort string // Sort key: date (default), from, to, subject, size or priority (outbox).
+	Asc  bool   // Sort in ascending order (default is descending).
+
+	Unread  *bool     // Only messages with the given unread state.
+	From    string    // Only messages from the given address (case-insensitive substring).
+	To      string    // Only messages to/cc the given address (case-insensitive substring).
+	Subject string    // Only messages with the given subject (case-insensitive substring).
+	Since   time.Time // Only messages dated at or after the given time.
+	Until   time.Time // Only messages dated before the given time.
+
+	Offset int // Number of messages to skip.
+	Limit  int // Maximum number of messages to return. Zero means no limit.
+}
+
+// IndexedMessages returns the messages in the given mailbox folder matching the query, along with
+// the total number of matching messages (before pagination).
+func (a *App) IndexedMessages(folder string, q MailboxQuery) ([]IndexedMessage, int, error) {
+	dir, err := a.folderPath(folder)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) && slices.Contains(BuiltinFolders, folder) {
+		return nil, 0, nil // Not created yet (e.g. trash)
+	} else if os.IsNotExist(err) {
+		return nil, 0, ErrFolderNotFound
+	}
+	msgs, err := a.mboxIndex.Messages(folder)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now()
+	for i, m := range msgs {
+		if folder != "out" {
+			msgs[i].Outbox = nil // Stale metadata of a sent or moved message.
+			continue
+		}
+		var meta OutboxMeta
+		if m.Outbox != nil {
+			meta = m.Outbox.OutboxMeta
+		}
+		msgs[i].Outbox = &OutboxStatus{OutboxMeta: meta, State: meta.State(now)}
+	}
+	page, total := q.Apply(msgs)
+	return page, total, nil
+}
+
+// Apply filters, sorts and paginates the given messages, returning the page and the total number of matching messages.
+func (q MailboxQuery) Apply(msgs []IndexedMessage) ([]IndexedMessage, int) {
+	contains := func(s, substr string) bool { return strings.Contains(strings.ToLower(s), strings.ToLower(substr)) }
+	anyAddr := func(addrs []fbb.Address, substr string) bool {
+		return slices.ContainsFunc(addrs, func(a fbb.Address) bool { return contains(a.Addr, substr) })
+	}
+	filtered := msgs[:0:0]
+	for _, m := range msgs {
+		switch {
+		case q.Unread != nil && m.Unread != *q.Unread,
+			q.From != "" && !contains(m.From.Addr, q.From),
+			q.To != "" && !anyAddr(m.To, q.To) && !anyAddr(m.Cc, q.To),
+			q.Subject != "" && !contains(m.Subject, q.Subject),
+			!q.Since.IsZero() && m.Date.Before(q.Since),
+			!q.Until.IsZero() && !m.Date.Before(q.Until):
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+
+	less := func(i, j int) bool { return filtered[i].Date.Before(filtered[j].Date) }
+	switch q.Sort {
+	case "from":
+		less = func(i, j int) bool {
+			return strings.ToLower(filtered[i].From.Addr) < strings.ToLower(filtered[j].From.Addr)
+		}
+	case "to":
+		first := func(m IndexedMessage) string {
+			if len(m.To) == 0 {
+				return ""
+			}
+			return strings.ToLower(m.To[0].Addr)
+		}
+		less = func(i, j int) bool { return first(filtered[i]) < first(filtered[j]) }
+	case "subject":
+		less = func(i, j int) bool {
+			return strings.ToLower(filtered[i].Subject) < strings.ToLower(filtered[j].Subject)
+		}
+	case "size":
+		less = func(i, j int) bool { return filtered[i].Size < filtered[j].Size }
+	case "priority":
+		priority := func(m IndexedMessage) int {
+			if m.Outbox == nil {
+				return 0
+			}
+			return m.Outbox.Priority
+		}
+		less = func(i, j int) bool { return priority(filtered[i]) < priority(filtered[j]) }
+	}
+	if q.Asc {
+		sort.SliceStable(filtered, less)
+	} else {
+		sort.SliceStable(filtered, func(i, j int) bool { return less(j, i) })
+	}
+
+	total := len(filtered)
+	if q.Offset > 0 {
+		filtered = filtered[min(q.Offset, total):]
+	}
+	if q.Limit > 0 && q.Limit < len(filtered) {
+		filtered = filtered[:q.Limit]
+	}
+	return filtered, total
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+func TestMailboxIndex(t *testing.T) {
+	a := newTestApp(t)
+
+	now := time.Now().Truncate(time.Minute)
+	var mids []string
+	for i, from := range []string{"LA5NTA", "LA1B", "N0CALL"} {
+		msg := fbb.NewMessage(fbb.Private, from)
+		msg.AddTo("LA3F")
+		msg.SetSubject("Report " + from)
+		msg.SetBody("Body")
+		msg.SetDate(now.Add(time.Duration(i) * time.Hour))
+		if i == 1 {
+			msg.AddFile(fbb.NewFile("photo.jpg", []byte("jpeg")))
+		}
+		if err := a.mbox.ProcessInbound(msg); err != nil {
+			t.Fatal(err)
+		}
+		mids = append(mids, msg.MID())
+	}
+
+	msgs, total, err := a.IndexedMessages("in", MailboxQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(msgs) != 3 || msgs[0].MID != mids[2] || msgs[2].MID != mids[0] {
+		t.Fatalf("unexpected listing (%d): %+v", total, msgs)
+	}
+	if msgs[1].Files[0].Name != "photo.jpg" || msgs[1].Files[0].Size != 4 || !msgs[1].Unread {
+		t.Errorf("unexpected metadata: %+v", msgs[1])
+	}
+	if _, err := os.Stat(filepath.Join(a.mbox.MBoxPath, mailboxIndexFile)); err != nil {
+		t.Errorf("expected index file to be written: %v", err)
+	}
+
+	// Changes are picked up by a re-opened index.
+	msg, err := mailbox.OpenMessage(filepath.Join(a.mbox.MBoxPath, "in", mids[0]+mailbox.Ext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mailbox.SetUnread(msg, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(a.mbox.MBoxPath, "in", mids[2]+mailbox.Ext)); err != nil {
+		t.Fatal(err)
+	}
+	a.mboxIndex = OpenMailboxIndex(a.mbox.MBoxPath)
+	if n := len(a.mboxIndex.folders["in"]); n != 3 {
+		t.Errorf("expected 3 persisted entries, got %d", n)
+	}
+	unread := true
+	msgs, total, err = a.IndexedMessages("in", MailboxQuery{Unread: &unread})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || msgs[0].MID != mids[1] {
+		t.Errorf("unexpected unread listing (%d): %+v", total, msgs)
+	}
+
+	// Pagination and sorting.
+	msgs, total, _ = a.IndexedMessages("in", MailboxQuery{Sort: "from", Asc: true, Limit: 1})
+	if total != 2 || len(msgs) != 1 || msgs[0].From.Addr != "LA1B" {
+		t.Errorf("unexpected first page (%d): %+v", total, msgs)
+	}
+	msgs, _, _ = a.IndexedMessages("in", MailboxQuery{Sort: "from", Asc: true, Offset: 1, Limit: 1})
+	if len(msgs) != 1 || msgs[0].From.Addr != "LA5NTA" {
+		t.Errorf("unexpected second page: %+v", msgs)
+	}
+	if msgs, total, _ = a.IndexedMessages("in", MailboxQuery{Offset: 5}); total != 2 || len(msgs) != 0 {
+		t.Errorf("expected empty page beyond end, got %d (%d)", len(msgs), total)
+	}
+
+	// Filesystem events.
+	if err := a.MoveMessage(mids[1], "in", "archive"); err != nil {
+		t.Fatal(err)
+	}
+	a.mboxIndex.Update(filepath.Join(a.mbox.MBoxPath, "in", mids[1]+mailbox.Ext))
+	if _, ok := a.mboxIndex.folders["in"][mids[1]+mailbox.Ext]; ok {
+		t.Error("expected moved message to be removed from the index")
+	}
+	if _, _, err := a.IndexedMessages("missing", MailboxQuery{}); err != ErrFolderNotFound {
+		t.Errorf("expected ErrFolderNotFound, got %v", err)
+	}
+}
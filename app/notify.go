@@ -0,0 +1,163 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/la5nta/pat/cfg"
+	"github.com/la5nta/pat/internal/buildinfo"
+	"github.com/la5nta/pat/internal/debug"
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+// Notification events.
+const (
+	NotifyMessage        = "message"         // A message was received.
+	NotifyServiceMessage = "service_message" // A service message (from SERVICE) was received.
+	NotifySessionFailed  = "session_failed"  // A session (exchange) failed.
+)
+
+const notifyTimeout = time.Minute
+
+// NotificationEvent is the payload delivered to notification sinks.
+type NotificationEvent struct {
+	Event   string          `json:"event"`
+	Time    time.Time       `json:"time"`
+	Message *MessageSummary `json:"message,omitempty"`
+	Session *Event          `json:"session,omitempty"`
+}
+
+// MessageSummary is a summary of a message, suitable for notifications.
+type MessageSummary struct {
+	MID         string    `json:"mid"`
+	Date        time.Time `json:"date"`
+	From        string    `json:"from"`
+	To          []string  `json:"to"`
+	Cc          []string  `json:"cc,omitempty"`
+	Subject     string    `json:"subject"`
+	Attachments []string  `json:"attachments,omitempty"`
+	P2POnly     bool      `json:"p2p_only"`
+}
+
+func NewMessageSummary(msg *fbb.Message) *MessageSummary {
+	s := &MessageSummary{
+		MID:     msg.MID(),
+		Date:    msg.Date(),
+		From:    msg.From().String(),
+		Subject: msg.Subject(),
+		P2POnly: msg.Header.Get("X-P2POnly") == "true",
+	}
+	for _, addr := range msg.To() {
+		s.To = append(s.To, addr.String())
+	}
+	for _, addr := range msg.Cc() {
+		s.Cc = append(s.Cc, addr.String())
+	}
+	for _, f := range msg.Files() {
+		s.Attachments = append(s.Attachments, f.Name())
+	}
+	return s
+}
+
+// notifyMessage delivers a notification of a received message to the configured sinks.
+func (a *App) notifyMessage(msg *fbb.Message) {
+	event := NotifyMessage
+	if isServiceMessage(msg) {
+		event = NotifyServiceMessage
+	}
+	a.notify(NotificationEvent{Event: event, Time: time.Now(), Message: NewMessageSummary(msg)})
+}
+
+// notifySessionFailed is an event log subscriber delivering notifications of failed sessions.
+//
+// It is always subscribed, the configured sinks are checked when a session fails.
+func (a *App) notifySessionFailed(e Event) {
+	if e.What != EventExchange || e.Success || len(a.config.Notifications) == 0 {
+		return
+	}
+	a.notify(NotificationEvent{Event: NotifySessionFailed, Time: e.LogTime, Session: &e})
+}
+
+// notify delivers the notification event asynchronously to the configured sinks.
+func (a *App) notify(n NotificationEvent) {
+	for _, sink := range a.config.Notifications {
+		if len(sink.Events) > 0 && !slices.Contains(sink.Events, n.Event) {
+			continue
+		}
+		go func(sink cfg.NotificationConfig) {
+			if err := a.deliverNotification(sink, n); err != nil {
+				log.Printf("Unable to deliver %s notification (%s): %v", n.Event, sink.Type, err)
+			}
+		}(sink)
+	}
+}
+
+func (a *App) deliverNotification(sink cfg.NotificationConfig, n NotificationEvent) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	switch sink.Type {
+	case cfg.NotificationWebhook:
+		return postWebhook(ctx, sink.URL, payload)
+	case cfg.NotificationExec:
+		cmd := exec.CommandContext(ctx, sink.Command, sink.Args...)
+		cmd.Env = append(append(os.Environ(), a.Env()...), notificationEnv(n)...)
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Stdout = log.Writer()
+		cmd.Stderr = log.Writer()
+		debug.Printf("Running notification command: %s", cmd)
+		return cmd.Run()
+	default:
+		return fmt.Errorf("unsupported notification type '%s'", sink.Type)
+	}
+}
+
+func postWebhook(ctx context.Context, url string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", buildinfo.UserAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
+
+func notificationEnv(n NotificationEvent) []string {
+	env := []string{"PAT_NOTIFY_EVENT=" + n.Event}
+	if m := n.Message; m != nil {
+		env = append(env,
+			"PAT_MESSAGE_MID="+m.MID,
+			"PAT_MESSAGE_FROM="+m.From,
+			"PAT_MESSAGE_TO="+strings.Join(m.To, " "),
+			"PAT_MESSAGE_SUBJECT="+m.Subject,
+		)
+	}
+	if e := n.Session; e != nil {
+		env = append(env, sessionEnv(*e)...)
+	}
+	return env
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/la5nta/pat/cfg"
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+func TestDeliverWebhookNotification(t *testing.T) {
+	received := make(chan NotificationEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n NotificationEvent
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Error(err)
+		}
+		received <- n
+	}))
+	defer srv.Close()
+
+	msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+	msg.AddTo("LA1B")
+	msg.SetSubject("Test")
+
+	a := New(Options{})
+	sink := cfg.NotificationConfig{Type: cfg.NotificationWebhook, URL: srv.URL}
+	if err := a.deliverNotification(sink, NotificationEvent{Event: NotifyMessage, Message: NewMessageSummary(msg)}); err != nil {
+		t.Fatal(err)
+	}
+	n := <-received
+	if n.Event != NotifyMessage || n.Message == nil || n.Message.Subject != "Test" || n.Message.From != "LA5NTA" {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+
+	sink.URL = srv.URL + "/%zz"
+	if err := a.deliverNotification(sink, NotificationEvent{Event: NotifyMessage}); err == nil {
+		t.Error("expected error for invalid URL")
+	}
+}
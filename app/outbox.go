@@ -0,0 +1,217 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/la5nta/pat/internal/debug"
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+// Outbox metadata headers. They are removed before a message is proposed.
+const (
+	headerHold      = "X-Pat-Hold"
+	headerPriority  = "X-Pat-Priority"
+	headerSendAfter = "X-Pat-Send-After"
+)
+
+// States of outbox messages.
+const (
+	OutboxReady     = "ready"
+	OutboxHeld      = "held"      // Not proposed until released.
+	OutboxScheduled = "scheduled" // Not proposed until the send after time.
+)
+
+// OutboxMeta is the sending metadata of an outbox message.
+type OutboxMeta struct {
+	Hold      bool      `json:"hold"`
+	Priority  int       `json:"priority"`   // Messages with a higher priority are proposed first.
+	SendAfter time.Time `json:"send_after"` // The message is not proposed before this time (if set).
+}
+
+// GetOutboxMeta returns the sending metadata of the given message.
+func GetOutboxMeta(msg *fbb.Message) OutboxMeta {
+	var meta OutboxMeta
+	meta.Hold = msg.Header.Get(headerHold) == "true"
+	meta.Priority, _ = strconv.Atoi(msg.Header.Get(headerPriority))
+	meta.SendAfter, _ = time.Parse(time.RFC3339, msg.Header.Get(headerSendAfter))
+	return meta
+}
+
+// SetOutboxMeta sets the sending metadata headers of the given message.
+func SetOutboxMeta(msg *fbb.Message, meta OutboxMeta) {
+	clearOutboxMeta(msg)
+	if meta.Hold {
+		msg.Header.Set(headerHold, "true")
+	}
+	if meta.Priority != 0 {
+		msg.Header.Set(headerPriority, strconv.Itoa(meta.Priority))
+	}
+	if !meta.SendAfter.IsZero() {
+		msg.Header.Set(headerSendAfter, meta.SendAfter.UTC().Format(time.RFC3339))
+	}
+}
+
+func clearOutboxMeta(msg *fbb.Message) {
+	msg.Header.Del(headerHold)
+	msg.Header.Del(headerPriority)
+	msg.Header.Del(headerSendAfter)
+}
+
+// State returns the outbox state of a message with this metadata at the given time.
+func (m OutboxMeta) State(now time.Time) string {
+	switch {
+	case m.Hold:
+		return OutboxHeld
+	case now.Before(m.SendAfter):
+		return OutboxScheduled
+	default:
+		return OutboxReady
+	}
+}
+
+// String formats the non-default metadata (e.g. "held, priority 2").
+func (m OutboxMeta) String() string {
+	var parts []string
+	if m.Hold {
+		parts = append(parts, "held")
+	}
+	if m.Priority != 0 {
+		parts = append(parts, fmt.Sprintf("priority %d", m.Priority))
+	}
+	if !m.SendAfter.IsZero() {
+		parts = append(parts, "send after "+m.SendAfter.Local().Format("2006-01-02 15:04"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Status formats the state at the given time, followed by the non-default metadata (e.g. "scheduled, send after 2026-06-01 08:00").
+func (m OutboxMeta) Status(now time.Time) string {
+	status := m.State(now)
+	m.Hold = false // Implied by the state.
+	if details := m.String(); details != "" {
+		status += ", " + details
+	}
+	return status
+}
+
+// UpdateOutboxMeta applies fn to the sending metadata of the outbox message with the given MID.
+func (a *App) UpdateOutboxMeta(mid string, fn func(meta *OutboxMeta)) (OutboxMeta, error) {
+	file, err := a.messageFile("out", mid)
+	if err != nil {
+		return OutboxMeta{}, err
+	}
+	msg, err := mailbox.OpenMessage(file)
+	if err != nil {
+		return OutboxMeta{}, err
+	}
+	meta := GetOutboxMeta(msg)
+	fn(&meta)
+	SetOutboxMeta(msg, meta)
+	msg.Header.Del("X-FilePath")
+	return meta, writeMessageFile(file, msg)
+}
+
+// ParseSendAfter parses a send after time given as RFC3339, a local date and time (YYYY-MM-DD HH:MM),
+// or a duration relative to now (e.g. 2h30m).
+func ParseSendAfter(str string, now time.Time) (time.Time, error) {
+	str = strings.TrimSpace(str)
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", str, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(strings.TrimPrefix(str, "+")); err == nil && d >= 0 {
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s' (expected RFC3339, YYYY-MM-DD HH:MM or duration like 2h)", str)
+}
+
+// eligibleOutbound returns the outbound messages to propose at the given time.
+//
+// Held, scheduled and invalid messages are left out, and local metadata headers are removed.
+// Since the session orders each proposal block by precedence and size, only the messages with the
+// highest pending priority are returned. Lower priorities are proposed in later turns of the
+// session, once these have been sent.
+func eligibleOutbound(msgs []*fbb.Message, now time.Time) []*fbb.Message {
+	eligible := msgs[:0:0]
+	var top int
+	for _, msg := range msgs {
+		meta := GetOutboxMeta(msg)
+		clearOutboxMeta(msg)
+		msg.Header.Del(headerReplyTo)
+		if state := meta.State(now); state != OutboxReady {
+			debug.Printf("Not proposing %s (%s)", msg.MID(), state)
+			continue
+		}
+		// The session ignores invalid messages. Leave them out here, so they don't block lower priorities.
+		if err := msg.Validate(); err != nil {
+			log.Printf("Ignoring invalid outbound message '%s': %s", msg.MID(), err)
+			continue
+		}
+		switch {
+		case len(eligible) == 0 || meta.Priority > top:
+			eligible, top = append(eligible[:0], msg), meta.Priority
+		case meta.Priority == top:
+			eligible = append(eligible, msg)
+		}
+	}
+	return eligible
+}
+
+// ReadyOutbound returns the outbox messages currently eligible for sending (in any priority).
+func (a *App) ReadyOutbound() ([]*fbb.Message, error) {
+	msgs, err := a.mbox.Outbox()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ready := msgs[:0]
+	for _, msg := range msgs {
+		if GetOutboxMeta(msg).State(now) == OutboxReady {
+			ready = append(ready, msg)
+		}
+	}
+	return ready, nil
+}
+
+// WriteQTC writes a listing of the outbox messages in the order they are proposed.
+func (a *App) WriteQTC(w io.Writer) error {
+	msgs, err := a.mbox.Outbox()
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return GetOutboxMeta(msgs[i]).Priority > GetOutboxMeta(msgs[j]).Priority
+	})
+	now := time.Now()
+	var ready int
+	for _, msg := range msgs {
+		if GetOutboxMeta(msg).State(now) == OutboxReady {
+			ready++
+		}
+	}
+	fmt.Fprintf(w, "QTC: %d (%d ready).\n", len(msgs), ready)
+	for _, msg := range msgs {
+		fmt.Fprintf(w, `%-12.12s (%s): %s`, msg.MID(), msg.Subject(), fmt.Sprint(msg.To()))
+		if msg.Header.Get("X-P2POnly") == "true" {
+			fmt.Fprintf(w, " (P2P only)")
+		}
+		if meta := GetOutboxMeta(msg); meta != (OutboxMeta{}) {
+			fmt.Fprintf(w, " [%s]", meta.Status(now))
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
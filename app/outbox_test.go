@@ -0,0 +1,120 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+func TestEligibleOutbound(t *testing.T) {
+	now := time.Now()
+	newMessage := func(meta OutboxMeta) *fbb.Message {
+		msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+		msg.AddTo("N0CALL")
+		msg.SetSubject("Test")
+		msg.SetBody("Body")
+		SetOutboxMeta(msg, meta)
+		return msg
+	}
+	msgs := []*fbb.Message{
+		newMessage(OutboxMeta{}),
+		newMessage(OutboxMeta{Priority: 5}),
+		newMessage(OutboxMeta{Priority: 9, Hold: true}),
+		newMessage(OutboxMeta{Priority: 9, SendAfter: now.Add(time.Hour)}),
+		newMessage(OutboxMeta{Priority: 5, SendAfter: now.Add(-time.Hour)}),
+	}
+	got := eligibleOutbound(msgs, now)
+	if len(got) != 2 || got[0] != msgs[1] || got[1] != msgs[4] {
+		t.Fatalf("expected the ready priority 5 messages, got %d message(s)", len(got))
+	}
+	for _, msg := range msgs {
+		if meta := GetOutboxMeta(msg); meta != (OutboxMeta{}) {
+			t.Errorf("expected metadata headers to be removed, got %+v", meta)
+		}
+	}
+	if got := eligibleOutbound(msgs[:1], now); len(got) != 1 {
+		t.Errorf("expected a single message, got %d", len(got))
+	}
+
+	// Invalid messages don't block lower priorities.
+	invalid := newMessage(OutboxMeta{Priority: 9})
+	invalid.Header.Del("To")
+	if got := eligibleOutbound([]*fbb.Message{invalid, newMessage(OutboxMeta{})}, now); len(got) != 1 || got[0] == invalid {
+		t.Errorf("expected the valid message only, got %d message(s)", len(got))
+	}
+}
+
+func TestParseSendAfter(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"2026-06-02T08:00:00Z": time.Date(2026, 6, 2, 8, 0, 0, 0, time.UTC),
+		"2026-06-02 08:00":     time.Date(2026, 6, 2, 8, 0, 0, 0, time.Local),
+		"2h30m":                now.Add(150 * time.Minute),
+		"+1h":                  now.Add(time.Hour),
+	}
+	for str, expect := range tests {
+		got, err := ParseSendAfter(str, now)
+		if err != nil || !got.Equal(expect) {
+			t.Errorf("%q: expected %v, got %v (%v)", str, expect, got, err)
+		}
+	}
+	for _, str := range []string{"", "tomorrow", "-1h"} {
+		if _, err := ParseSendAfter(str, now); err == nil {
+			t.Errorf("%q: expected error", str)
+		}
+	}
+}
+
+func TestUpdateOutboxMeta(t *testing.T) {
+	a := newTestApp(t)
+
+	var mids []string
+	for _, subject := range []string{"Routine", "Urgent"} {
+		msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+		msg.AddTo("N0CALL")
+		msg.SetSubject(subject)
+		msg.SetBody("Body")
+		if err := a.mbox.AddOut(msg); err != nil {
+			t.Fatal(err)
+		}
+		mids = append(mids, msg.MID())
+	}
+
+	if _, err := a.UpdateOutboxMeta(mids[0], func(meta *OutboxMeta) { meta.Hold = true }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.UpdateOutboxMeta(mids[1], func(meta *OutboxMeta) { meta.Priority = 3 }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.UpdateOutboxMeta("MISSING", func(*OutboxMeta) {}); err == nil {
+		t.Error("expected error for missing message")
+	}
+
+	ready, err := a.ReadyOutbound()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ready) != 1 || ready[0].MID() != mids[1] {
+		t.Errorf("expected only the urgent message to be ready, got %d message(s)", len(ready))
+	}
+
+	msgs, _, err := a.IndexedMessages("out", MailboxQuery{Sort: "priority"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].MID != mids[1] || msgs[0].Outbox.State != OutboxReady || msgs[0].Outbox.Priority != 3 {
+		t.Fatalf("unexpected listing: %+v", msgs)
+	}
+	if msgs[1].Outbox.State != OutboxHeld {
+		t.Errorf("expected held state, got %q", msgs[1].Outbox.State)
+	}
+
+	// Released messages are ready again.
+	if _, err := a.UpdateOutboxMeta(mids[0], func(meta *OutboxMeta) { meta.Hold = false }); err != nil {
+		t.Fatal(err)
+	}
+	if ready, _ := a.ReadyOutbound(); len(ready) != 2 {
+		t.Errorf("expected both messages to be ready, got %d", len(ready))
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/la5nta/pat/internal/debug"
+	"github.com/la5nta/pat/internal/prehook"
+)
+
+const posthookTimeout = 5 * time.Minute
+
+// posthookPath returns the path of the posthook executable.
+//
+// Like prehooks, an executable given by name is looked up in the prehooks directory
+// ({CONFIG_DIR}/prehooks/ by default) before $PATH.
+func (a *App) posthookPath(file string) (string, error) {
+	if dir := a.options.PrehooksPath; dir != "" && filepath.Base(file) == file {
+		if path := filepath.Join(dir, file); prehook.Verify(path) == nil {
+			return path, nil
+		}
+	}
+	path, err := exec.LookPath(file)
+	if errors.Is(err, exec.ErrDot) {
+		err = nil
+	}
+	return path, err
+}
+
+// posthook returns an event log subscriber executing the given posthook after each session.
+func (a *App) posthook(file string, args []string) func(Event) {
+	return func(e Event) {
+		if e.What != EventExchange {
+			return
+		}
+		go func() {
+			if err := a.runPosthook(file, args, e); err != nil {
+				log.Printf("Posthook failed: %v", err)
+			}
+		}()
+	}
+}
+
+// runPosthook executes the posthook with the session's exchange event passed
+// as environment variables and as JSON on stdin.
+func (a *App) runPosthook(file string, args []string, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), posthookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, file, args...)
+	cmd.Env = append(append(os.Environ(), a.Env()...), sessionEnv(e)...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = log.Writer()
+	debug.Printf("Running posthook: %s", cmd)
+	return cmd.Run()
+}
+
+func sessionEnv(e Event) []string {
+	return []string{
+		"PAT_SESSION_MYCALL=" + e.MyCall,
+		"PAT_SESSION_TARGET=" + e.TargetCall,
+		"PAT_SESSION_MASTER=" + strconv.FormatBool(e.Master),
+		"PAT_SESSION_NETWORK=" + e.Network,
+		"PAT_SESSION_REMOTE_ADDR=" + e.RemoteAddr,
+		"PAT_SESSION_SENT=" + strings.Join(e.Sent, " "),
+		"PAT_SESSION_RECEIVED=" + strings.Join(e.Received, " "),
+		"PAT_SESSION_SUCCESS=" + strconv.FormatBool(e.Success),
+		"PAT_SESSION_ERROR=" + e.Error,
+		"PAT_SESSION_DURATION=" + strconv.Itoa(int(e.Duration().Seconds())),
+	}
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunPosthook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	script := `echo "$PAT_SESSION_TARGET $PAT_SESSION_RECEIVED $PAT_SESSION_SUCCESS" > "$1"; cat >> "$1"`
+	e := Event{What: EventExchange, TargetCall: "LA1B", Received: []string{"MID1", "MID2"}, Success: true}
+
+	a := New(Options{})
+	if err := a.runPosthook("sh", []string{"-c", script, "sh", out}, e); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, stdin, _ := strings.Cut(string(b), "\n")
+	if env != "LA1B MID1 MID2 true" {
+		t.Errorf("unexpected environment: %q", env)
+	}
+	var got Event
+	if err := json.Unmarshal([]byte(stdin), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.TargetCall != "LA1B" || len(got.Received) != 2 {
+		t.Errorf("unexpected event on stdin: %+v", got)
+	}
+}
+
+func TestPosthookPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires executable file mode")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "print-forms")
+	if err := os.WriteFile(file, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	a := New(Options{PrehooksPath: dir})
+	if path, err := a.posthookPath("print-forms"); err != nil || path != file {
+		t.Errorf("expected posthook in prehooks directory, got %q (%v)", path, err)
+	}
+	if _, err := a.posthookPath("no-such-posthook"); err == nil {
+		t.Error("expected error for missing posthook")
+	}
+}
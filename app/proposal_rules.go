@@ -0,0 +1,96 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/la5nta/pat/cfg"
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+// transportName returns the transport name of the given connection network or connect URL scheme
+// (e.g. "AX.25" -> "ax25", "ax25+linux" -> "ax25").
+func transportName(network string) string {
+	switch network = strings.ToLower(network); {
+	case network == "tcp", network == "tcp4", network == "tcp6":
+		return MethodTelnet
+	case network == "ax.25", network == MethodSerialTNCDeprecated, strings.HasPrefix(network, MethodAX25+"+"):
+		return MethodAX25
+	default:
+		return network
+	}
+}
+
+// activeTransport returns the transport name of the active session, if any.
+func (a *App) activeTransport() string {
+	if conn := a.exchangeConn; conn != nil && conn.RemoteAddr() != nil {
+		return transportName(conn.RemoteAddr().Network())
+	}
+	return ""
+}
+
+// autoDownloadSizeLimit returns the size limit for automatic download over the given transport.
+func (a *App) autoDownloadSizeLimit(transport string) int {
+	if limit, ok := a.config.AutoDownloadSizeLimits[transport]; ok {
+		return limit
+	}
+	return a.config.AutoDownloadSizeLimit
+}
+
+// proposalRuleAnswer returns the answer of the first proposal rule matching the given proposal.
+func (a *App) proposalRuleAnswer(p *fbb.Proposal, transport string) (fbb.ProposalAnswer, bool) {
+	for i, rule := range a.config.ProposalRules {
+		ok, err := matchProposalRule(rule, p, transport)
+		if err != nil {
+			log.Printf("Proposal rule #%d: %v", i+1, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(rule.Action) {
+		case cfg.ProposalAccept:
+			return fbb.Accept, true
+		case cfg.ProposalDefer:
+			return fbb.Defer, true
+		case cfg.ProposalReject:
+			return fbb.Reject, true
+		default:
+			log.Printf("Proposal rule #%d: invalid action '%s'", i+1, rule.Action)
+		}
+	}
+	return 0, false
+}
+
+func matchProposalRule(rule cfg.ProposalRule, p *fbb.Proposal, transport string) (bool, error) {
+	subject := p.Title()
+	var from fbb.Address
+	if pm := p.PendingMessage(); pm != nil {
+		subject, from = pm.Subject, pm.From
+	}
+	if rule.Transport != "" && !strings.EqualFold(rule.Transport, transport) {
+		return false, nil
+	}
+	if rule.MinSize > 0 && p.CompressedSize() < rule.MinSize {
+		return false, nil
+	}
+	if rule.From != "" && (from.IsZero() || !matchAddress(rule.From, from)) {
+		return false, nil
+	}
+	if rule.Subject != "" {
+		re, err := regexp.Compile(rule.Subject)
+		if err != nil {
+			return false, fmt.Errorf("invalid subject expression: %w", err)
+		}
+		if !re.MatchString(subject) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/la5nta/pat/cfg"
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+func TestProposalRuleAnswer(t *testing.T) {
+	a := New(Options{})
+	a.config.AutoDownloadSizeLimit = 10000
+	a.config.AutoDownloadSizeLimits = map[string]int{MethodPactor: 5000, MethodTelnet: -1}
+	a.config.ProposalRules = []cfg.ProposalRule{
+		{Subject: "(?i)^urgent", Action: "accept"},
+		{Subject: "(?i)weather", Transport: "pactor", Action: "defer"},
+		{MinSize: 100000, Action: "reject"},
+	}
+
+	small := fbb.NewProposal("MID1", "Weather report", fbb.GzipProposal, []byte("forecast"))
+	photo := make([]byte, 200000)
+	rand.New(rand.NewSource(1)).Read(photo) // Incompressible
+	large := fbb.NewProposal("MID2", "Photos", fbb.GzipProposal, photo)
+
+	tests := []struct {
+		prop      *fbb.Proposal
+		transport string
+		expect    fbb.ProposalAnswer
+		ok        bool
+	}{
+		{small, MethodPactor, fbb.Defer, true},
+		{small, MethodTelnet, 0, false},
+		{large, MethodTelnet, fbb.Reject, true},
+		{fbb.NewProposal("MID3", "URGENT: Water", fbb.GzipProposal, []byte("help")), MethodPactor, fbb.Accept, true},
+	}
+	for _, tt := range tests {
+		got, ok := a.proposalRuleAnswer(tt.prop, tt.transport)
+		if got != tt.expect || ok != tt.ok {
+			t.Errorf("%s over %s: expected %c (%t), got %c (%t)", tt.prop.Title(), tt.transport, tt.expect, tt.ok, got, ok)
+		}
+	}
+
+	for transport, expect := range map[string]int{MethodPactor: 5000, MethodTelnet: -1, MethodArdop: 10000} {
+		if got := a.autoDownloadSizeLimit(transport); got != expect {
+			t.Errorf("size limit for %s: expected %d, got %d", transport, expect, got)
+		}
+	}
+	if got := transportName("AX.25"); got != MethodAX25 {
+		t.Errorf("unexpected transport name for AX.25: %s", got)
+	}
+}
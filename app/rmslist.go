@@ -48,15 +48,16 @@ type Prediction struct {
 }
 
 type RMS struct {
-	Callsign   string      `json:"callsign"`
-	Gridsquare string      `json:"gridsquare"`
-	Distance   JSONFloat64 `json:"distance"`
-	Azimuth    JSONFloat64 `json:"azimuth"`
-	Modes      string      `json:"modes"`
-	Freq       Frequency   `json:"freq"`
-	Dial       Frequency   `json:"dial"`
-	URL        *JSONURL    `json:"url"`
-	Prediction *Prediction `json:"prediction"`
+	Callsign   string        `json:"callsign"`
+	Gridsquare string        `json:"gridsquare"`
+	Distance   JSONFloat64   `json:"distance"`
+	Azimuth    JSONFloat64   `json:"azimuth"`
+	Modes      string        `json:"modes"`
+	Freq       Frequency     `json:"freq"`
+	Dial       Frequency     `json:"dial"`
+	URL        *JSONURL      `json:"url"`
+	Prediction *Prediction   `json:"prediction"`
+	History    *GatewayStats `json:"history"`
 }
 
 func (r RMS) IsMode(mode string) bool {
@@ -91,6 +92,34 @@ func (r ByLinkQuality) Less(i, j int) bool {
 	return sort.Reverse(ByDist(r)).Less(i, j)
 }
 
+// ByHistory sorts by success rate, then mean throughput, as recorded in the
+// connection history. Gateways without history are sorted by link quality.
+type ByHistory []RMS
+
+func (r ByHistory) Len() int      { return len(r) }
+func (r ByHistory) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r ByHistory) Less(i, j int) bool {
+	value := func(idx int, f func(GatewayStats) JSONFloat64) float64 {
+		if r[idx].History == nil {
+			return -1
+		}
+		v := float64(f(*r[idx].History))
+		if math.IsNaN(v) {
+			return -1
+		}
+		return v
+	}
+	successRate := func(s GatewayStats) JSONFloat64 { return s.SuccessRate }
+	throughput := func(s GatewayStats) JSONFloat64 { return s.MeanThroughput }
+	if is, js := value(i, successRate), value(j, successRate); is != js {
+		return is < js
+	}
+	if it, jt := value(i, throughput), value(j, throughput); it != jt {
+		return it < jt
+	}
+	return ByLinkQuality(r).Less(i, j)
+}
+
 type ByDist []RMS
 
 func (r ByDist) Len() int      { return len(r) }
@@ -129,6 +158,11 @@ func (a *App) ReadRMSList(ctx context.Context, forceDownload bool, filterFn func
 		return nil, err
 	}
 
+	history, err := a.History()
+	if err != nil {
+		log.Printf("Unable to load connection history: %v", err)
+	}
+
 	slice := []RMS{}
 	for _, gw := range status.Gateways {
 		for _, channel := range gw.Channels {
@@ -147,6 +181,11 @@ func (a *App) ReadRMSList(ctx context.Context, forceDownload bool, filterFn func
 				r.Distance = JSONFloat64(me.Distance(them))
 				r.Azimuth = JSONFloat64(me.Bearing(them))
 			}
+			if history != nil {
+				if stats, ok := history.Stats(r.Callsign, r.Dial); ok {
+					r.History = &stats
+				}
+			}
 			if keep := filterFn(r); !keep {
 				continue
 			}
@@ -0,0 +1,380 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorhill/cronexpr"
+	"github.com/la5nta/pat/internal/debug"
+	"github.com/la5nta/pat/internal/sun"
+	"github.com/pd0mz/go-maidenhead"
+)
+
+var (
+	ErrJobNotFound   = errors.New("job not found")
+	ErrSchedulerBusy = errors.New("scheduler busy")
+)
+
+// ScheduledJob is a command executed by the Scheduler.
+type ScheduledJob struct {
+	ID         int       `json:"id"`
+	Expr       string    `json:"expr"`
+	Command    string    `json:"command"`
+	Conditions []string  `json:"conditions,omitempty"`
+	Next       time.Time `json:"next"`
+	LastRun    *JobRun   `json:"last_run"`
+
+	schedule schedule
+	conds    []ScheduleCondition
+}
+
+// JobRun is the result of the execution of a scheduled job.
+type JobRun struct {
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"duration"` // Seconds
+	Success  bool      `json:"success"`
+	Skipped  bool      `json:"skipped,omitempty"` // True if the command was skipped due to an unmet condition.
+	Error    string    `json:"error,omitempty"`
+}
+
+// CommandExecutor executes scheduled commands.
+type CommandExecutor interface {
+	ExecCommand(cmd string) error
+	CheckCondition(c ScheduleCondition) (bool, error)
+
+	// Locator returns the station location used for sunrise/sunset schedules.
+	Locator() string
+}
+
+type schedule interface{ Next(time.Time) time.Time }
+
+func parseSchedule(expr string, locator func() string) (schedule, error) {
+	if strings.HasPrefix(expr, "@sun") {
+		return parseSunSchedule(expr, locator)
+	}
+	return cronexpr.Parse(expr)
+}
+
+// sunSchedule is a schedule relative to local sunrise or sunset (e.g. @sunset-30m).
+type sunSchedule struct {
+	sunset  bool
+	offset  time.Duration
+	locator func() string
+}
+
+func parseSunSchedule(expr string, locator func() string) (sunSchedule, error) {
+	s := sunSchedule{locator: locator}
+	var offset string
+	switch {
+	case strings.HasPrefix(expr, "@sunrise"):
+		offset = strings.TrimPrefix(expr, "@sunrise")
+	case strings.HasPrefix(expr, "@sunset"):
+		s.sunset, offset = true, strings.TrimPrefix(expr, "@sunset")
+	default:
+		return s, errors.New("expected @sunrise or @sunset")
+	}
+	if offset != "" {
+		if offset[0] != '+' && offset[0] != '-' {
+			return s, fmt.Errorf("invalid offset '%s' (expected e.g. -30m or +1h)", offset)
+		}
+		d, err := time.ParseDuration(offset)
+		if err != nil {
+			return s, fmt.Errorf("invalid offset: %w", err)
+		}
+		s.offset = d
+	}
+	if _, err := parseLocator(locator()); err != nil {
+		return s, fmt.Errorf("a valid locator is required for sunrise/sunset schedules: %w", err)
+	}
+	return s, nil
+}
+
+// Next returns the time of the next sunrise/sunset (including offset) after t, or the zero
+// time if the sun doesn't rise/set within the next year.
+func (s sunSchedule) Next(t time.Time) time.Time {
+	// The locator is resolved on each call, as it may be updated by GPSd.
+	p, err := parseLocator(s.locator())
+	if err != nil {
+		return time.Time{}
+	}
+	for day := -1; day <= 366; day++ {
+		rise, set, ok := sun.Events(t.AddDate(0, 0, day), p.Latitude, p.Longitude)
+		if !ok {
+			continue // Polar day or night
+		}
+		next := rise
+		if s.sunset {
+			next = set
+		}
+		if next = next.Add(s.offset); next.After(t) {
+			return next.Local()
+		}
+	}
+	return time.Time{}
+}
+
+func parseLocator(locator string) (maidenhead.Point, error) {
+	if locator == "" {
+		return maidenhead.Point{}, errors.New("locator not set")
+	}
+	return maidenhead.ParseLocator(locator)
+}
+
+// Scheduler executes commands according to cron-like schedule expressions.
+//
+// Jobs are executed one at a time, in the order they become due.
+type Scheduler struct {
+	exec CommandExecutor
+
+	mu     sync.Mutex
+	jobs   []*ScheduledJob
+	nextID int
+
+	triggered chan int
+}
+
+func NewScheduler(exec CommandExecutor) *Scheduler {
+	return &Scheduler{exec: exec, nextID: 1, triggered: make(chan int, 16)}
+}
+
+// Add adds a new job executing cmd according to the given schedule expression.
+//
+// The command may be followed by conditions (see ScheduleCondition).
+func (s *Scheduler) Add(expr, cmd string) (ScheduledJob, error) {
+	sched, err := parseSchedule(expr, s.exec.Locator)
+	if err != nil {
+		return ScheduledJob{}, fmt.Errorf("invalid schedule expression '%s': %w", expr, err)
+	}
+	cmd, conds, err := splitConditions(cmd)
+	switch {
+	case err != nil:
+		return ScheduledJob{}, err
+	case cmd == "":
+		return ScheduledJob{}, errors.New("missing command")
+	}
+	var condStrs []string
+	for _, c := range conds {
+		condStrs = append(condStrs, c.String())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := &ScheduledJob{
+		ID:         s.nextID,
+		Expr:       expr,
+		Command:    cmd,
+		Conditions: condStrs,
+		Next:       sched.Next(time.Now()),
+		schedule:   sched,
+		conds:      conds,
+	}
+	s.nextID++
+	s.jobs = append(s.jobs, j)
+	return *j, nil
+}
+
+// Remove removes the job with the given ID.
+func (s *Scheduler) Remove(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, j := range s.jobs {
+		if j.ID == id {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			return nil
+		}
+	}
+	return ErrJobNotFound
+}
+
+// Jobs returns a snapshot of the scheduled jobs.
+func (s *Scheduler) Jobs() []ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]ScheduledJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, *j)
+	}
+	return jobs
+}
+
+// Job returns a snapshot of the job with the given ID.
+func (s *Scheduler) Job(id int) (ScheduledJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j := s.job(id); j != nil {
+		return *j, true
+	}
+	return ScheduledJob{}, false
+}
+
+func (s *Scheduler) job(id int) *ScheduledJob {
+	for _, j := range s.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// Trigger queues the job with the given ID for immediate execution, regardless of its conditions.
+func (s *Scheduler) Trigger(id int) error {
+	if _, ok := s.Job(id); !ok {
+		return ErrJobNotFound
+	}
+	select {
+	case s.triggered <- id:
+		return nil
+	default:
+		return ErrSchedulerBusy
+	}
+}
+
+// Run executes due jobs until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-s.triggered:
+			s.mu.Lock()
+			j := s.job(id)
+			s.mu.Unlock()
+			if j != nil {
+				s.run(j)
+			}
+		case now := <-t.C:
+			for _, j := range s.due(now) {
+				if cond, ok := s.unmetCondition(j); ok {
+					s.skip(j, cond)
+					continue
+				}
+				s.run(j)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) due(now time.Time) []*ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*ScheduledJob
+	for _, j := range s.jobs {
+		if !j.Next.IsZero() && !now.Before(j.Next) {
+			due = append(due, j)
+		}
+	}
+	return due
+}
+
+// unmetCondition returns the first condition of the job that is not met, if any.
+func (s *Scheduler) unmetCondition(j *ScheduledJob) (ScheduleCondition, bool) {
+	for _, c := range j.conds {
+		ok, err := s.exec.CheckCondition(c)
+		if err != nil {
+			log.Printf("Unable to check condition '%s' of scheduled command '%s': %v", c, j.Command, err)
+		}
+		if !ok {
+			return c, true
+		}
+	}
+	return ScheduleCondition{}, false
+}
+
+func (s *Scheduler) skip(j *ScheduledJob, cond ScheduleCondition) {
+	debug.Printf("Skipping scheduled command '%s': condition '%s' not met", j.Command, cond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.LastRun = &JobRun{Time: time.Now(), Skipped: true, Error: fmt.Sprintf("condition '%s' not met", cond)}
+	j.Next = j.schedule.Next(time.Now())
+}
+
+func (s *Scheduler) run(j *ScheduledJob) {
+	log.Printf("Executing scheduled command '%s'...", j.Command)
+	start := time.Now()
+	err := s.exec.ExecCommand(j.Command)
+	run := &JobRun{Time: start, Duration: time.Since(start).Seconds(), Success: err == nil}
+	if err != nil {
+		run.Error = err.Error()
+		log.Printf("Scheduled command '%s' failed: %v", j.Command, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.LastRun = run
+	j.Next = j.schedule.Next(time.Now())
+}
+
+// Scheduler returns the command scheduler, or nil if the current command is not long-lived.
+func (a *App) Scheduler() *Scheduler { return a.scheduler }
+
+func (a *App) startScheduler(ctx context.Context) {
+	a.scheduler = NewScheduler(a)
+	exprs := make([]string, 0, len(a.config.Schedule))
+	for expr := range a.config.Schedule {
+		exprs = append(exprs, expr)
+	}
+	sort.Strings(exprs) // Stable job IDs
+	for _, expr := range exprs {
+		if _, err := a.scheduler.Add(expr, a.config.Schedule[expr]); err != nil {
+			log.Printf("Ignoring scheduled command: %v", err)
+		}
+	}
+	go a.scheduler.Run(ctx)
+}
+
+// ExecCommand executes a command line (as used in the command schedule).
+//
+// Supported commands are connect, listen, unlisten, freq, heard, qtc and debug. Output is written
+// to the terminal (and the log file).
+func (a *App) ExecCommand(line string) error {
+	cmd, param, _ := strings.Cut(strings.TrimSpace(line), " ")
+	param = strings.TrimSpace(param)
+	switch cmd {
+	case "connect":
+		if param == "" {
+			return errors.New("missing connect url or alias")
+		}
+		if !a.ConnectChain(strings.Fields(param)...) {
+			return errors.New("connect failed")
+		}
+	case "listen":
+		a.Listen(param)
+	case "unlisten":
+		a.Unlisten(param)
+	case "freq":
+		return a.Freq(a.stdout(), param)
+	case "heard":
+		a.WriteHeard(a.stdout())
+	case "qtc":
+		return a.WriteQTC(a.stdout())
+	case "debug":
+		os.Setenv("ardop_debug", "1")
+		fmt.Fprintln(a.stdout(), "Number of goroutines:", runtime.NumGoroutine())
+	default:
+		return fmt.Errorf("unsupported command '%s'", cmd)
+	}
+	return nil
+}
+
+// stdout returns the writer for command output (the terminal and the log file, if initialized).
+func (a *App) stdout() io.Writer {
+	if a.termWriter != nil {
+		return a.termWriter
+	}
+	return os.Stdout
+}
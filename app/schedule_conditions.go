@@ -0,0 +1,117 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scheduled command conditions.
+const (
+	CondOutbox       = "outbox"        // The outbox has messages ready to be sent (not held or scheduled).
+	CondIdle         = "idle"          // No session is active or being dialed.
+	CondListening    = "listening"     // The given listener is active (e.g. listening=ardop).
+	CondLastExchange = "last-exchange" // The last successful exchange is older than the given age (e.g. last-exchange>2h).
+)
+
+// ScheduleCondition is a condition evaluated when a scheduled command is due.
+//
+// Conditions are appended to the command with "if", and combined with "and":
+//
+//	connect telnet if outbox and idle
+//	connect ardop:///LA1B if last-exchange>6h and listening=ardop
+type ScheduleCondition struct {
+	Name      string
+	Transport string        // For CondListening.
+	Age       time.Duration // For CondLastExchange.
+}
+
+func (c ScheduleCondition) String() string {
+	switch c.Name {
+	case CondListening:
+		return c.Name + "=" + c.Transport
+	case CondLastExchange:
+		return c.Name + ">" + c.Age.String()
+	default:
+		return c.Name
+	}
+}
+
+// splitConditions splits a scheduled command line into the command and its conditions.
+func splitConditions(line string) (cmd string, conds []ScheduleCondition, err error) {
+	cmd, condStr, ok := strings.Cut(line, " if ")
+	cmd = strings.TrimSpace(cmd)
+	if !ok {
+		return cmd, nil, nil
+	}
+	for _, str := range strings.Split(condStr, " and ") {
+		c, err := parseScheduleCondition(strings.TrimSpace(str))
+		if err != nil {
+			return cmd, nil, err
+		}
+		conds = append(conds, c)
+	}
+	return cmd, conds, nil
+}
+
+func parseScheduleCondition(str string) (ScheduleCondition, error) {
+	switch {
+	case str == CondOutbox, str == CondIdle:
+		return ScheduleCondition{Name: str}, nil
+	case strings.HasPrefix(str, CondListening+"="):
+		transport := strings.TrimPrefix(str, CondListening+"=")
+		if transport == "" {
+			return ScheduleCondition{}, fmt.Errorf("missing transport in condition '%s'", str)
+		}
+		return ScheduleCondition{Name: CondListening, Transport: transport}, nil
+	case strings.HasPrefix(str, CondLastExchange+">"):
+		age, err := parseAge(strings.TrimPrefix(str, CondLastExchange+">"))
+		if err != nil {
+			return ScheduleCondition{}, fmt.Errorf("invalid age in condition '%s': %w", str, err)
+		}
+		return ScheduleCondition{Name: CondLastExchange, Age: age}, nil
+	default:
+		return ScheduleCondition{}, fmt.Errorf("unknown condition '%s'", str)
+	}
+}
+
+// parseAge parses a duration, allowing days as unit (e.g. 2d).
+func parseAge(str string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(str, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(str)
+}
+
+// CheckCondition reports whether the given scheduled command condition is met.
+func (a *App) CheckCondition(c ScheduleCondition) (bool, error) {
+	switch c.Name {
+	case CondOutbox:
+		msgs, err := a.ReadyOutbound()
+		return len(msgs) > 0, err
+	case CondIdle:
+		status := a.GetStatus()
+		return !status.Connected && !status.Dialing, nil
+	case CondListening:
+		return slices.Contains(a.ActiveListeners(), c.Transport), nil
+	case CondLastExchange:
+		success := true
+		events, err := a.Events(EventFilter{What: EventExchange, Success: &success})
+		if err != nil || len(events) == 0 {
+			return true, err
+		}
+		return time.Since(events[len(events)-1].LogTime) > c.Age, nil
+	default:
+		return false, fmt.Errorf("unknown condition '%s'", c.Name)
+	}
+}
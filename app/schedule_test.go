@@ -0,0 +1,170 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+func TestScheduler(t *testing.T) {
+	executed := make(chan string, 1)
+	s := NewScheduler(fakeExecutor{
+		exec: func(cmd string) error {
+			executed <- cmd
+			return errors.New("connect failed")
+		},
+	})
+
+	if _, err := s.Add("not an expression", "connect telnet"); err == nil {
+		t.Error("expected error for invalid expression")
+	}
+	if _, err := s.Add("@yearly", "connect telnet if sunny"); err == nil {
+		t.Error("expected error for unknown condition")
+	}
+	job, err := s.Add("@yearly", "connect telnet if outbox and last-exchange>2d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Command != "connect telnet" || len(job.Conditions) != 2 || job.Conditions[1] != "last-exchange>48h0m0s" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	if err := s.Trigger(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cmd := <-executed:
+		if cmd != "connect telnet" {
+			t.Errorf("unexpected command executed: %q", cmd)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("triggered job not executed")
+	}
+
+	// Wait for the result to be recorded.
+	deadline := time.Now().Add(time.Second)
+	for {
+		job, _ = s.Job(job.ID)
+		if job.LastRun != nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.LastRun == nil || job.LastRun.Success || job.LastRun.Error != "connect failed" {
+		t.Errorf("unexpected last run: %+v", job.LastRun)
+	}
+
+	if err := s.Remove(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Trigger(job.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestSchedulerConditions(t *testing.T) {
+	s := NewScheduler(fakeExecutor{
+		exec:  func(cmd string) error { t.Errorf("unexpected execution of %q", cmd); return nil },
+		check: func(c ScheduleCondition) bool { return c.Name != CondOutbox },
+	})
+	job, err := s.Add("* * * * *", "connect telnet if idle and outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := s.job(job.ID)
+	if c, ok := s.unmetCondition(j); !ok || c.Name != CondOutbox {
+		t.Fatalf("expected unmet outbox condition, got %v (%t)", c, ok)
+	}
+	s.skip(j, ScheduleCondition{Name: CondOutbox})
+	if job, _ := s.Job(job.ID); job.LastRun == nil || !job.LastRun.Skipped {
+		t.Errorf("expected skipped last run, got %+v", job.LastRun)
+	}
+}
+
+type fakeExecutor struct {
+	exec    func(cmd string) error
+	check   func(c ScheduleCondition) bool
+	locator string
+}
+
+func (f fakeExecutor) ExecCommand(cmd string) error { return f.exec(cmd) }
+func (f fakeExecutor) Locator() string              { return f.locator }
+func (f fakeExecutor) CheckCondition(c ScheduleCondition) (bool, error) {
+	if f.check == nil {
+		return true, nil
+	}
+	return f.check(c), nil
+}
+
+func TestSunSchedule(t *testing.T) {
+	locator := func() string { return "JP20qe" } // Hagavik, Norway
+	now := time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC)
+
+	s, err := parseSchedule("@sunset-30m", locator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := s.Next(now).UTC()
+	if next.Day() != 21 || next.Hour() != 20 {
+		t.Errorf("unexpected next sunset-30m: %s", next)
+	}
+
+	s, err = parseSchedule("@sunrise", locator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(now).UTC(); next.Day() != 22 || next.Hour() != 2 {
+		t.Errorf("unexpected next sunrise: %s", next)
+	}
+
+	for _, expr := range []string{"@sunset30m", "@sundown", "@sunrise+1x"} {
+		if _, err := parseSchedule(expr, locator); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+	if _, err := parseSchedule("@sunrise", func() string { return "" }); err == nil {
+		t.Error("expected error for missing locator")
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	a := newTestApp(t)
+	var buf bytes.Buffer
+	a.termWriter = nopCloser{&buf}
+	t.Setenv("ardop_debug", "") // Restored after the debug command.
+
+	msg := fbb.NewMessage(fbb.Private, "N0CALL")
+	msg.AddTo("LA5NTA")
+	msg.SetSubject("Pending")
+	msg.SetBody("Body")
+	if err := a.mbox.AddOut(msg); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"qtc", "heard", "debug"} {
+		if err := a.ExecCommand(line); err != nil {
+			t.Errorf("%s: %v", line, err)
+		}
+	}
+	if !strings.Contains(buf.String(), "QTC: 1 (1 ready).") || !strings.Contains(buf.String(), "Number of goroutines") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+	for _, line := range []string{"freq", "bogus"} {
+		if err := a.ExecCommand(line); err == nil {
+			t.Errorf("%s: expected error", line)
+		}
+	}
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
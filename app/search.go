@@ -0,0 +1,162 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"html"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/la5nta/pat/internal/forms"
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+// Searchable message fields.
+const (
+	SearchFieldSubject    = "subject"
+	SearchFieldFrom       = "from"
+	SearchFieldTo         = "to"
+	SearchFieldCc         = "cc"
+	SearchFieldBody       = "body"
+	SearchFieldAttachment = "attachment"
+	SearchFieldForm       = "form"
+)
+
+// SearchResult is a message matching a search query.
+type SearchResult struct {
+	Folder string `json:"folder"`
+	*MessageSummary
+	Unread bool `json:"unread"`
+
+	// The fields matching one or more of the search terms.
+	Matches []string `json:"matches"`
+}
+
+// Search returns the messages matching all terms of the given query, most recent first.
+//
+// Terms are matched case-insensitively against the subject, addresses, body text, attachment
+// names and form field values. Phrases can be given in double quotes (e.g. "shelter 4").
+// All folders except the trash are searched unless folders are given.
+func (a *App) Search(query string, folders ...string) ([]SearchResult, error) {
+	terms := ParseSearchQuery(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if len(folders) == 0 {
+		all, err := a.folderNames()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range all {
+			if name != TrashFolder {
+				folders = append(folders, name)
+			}
+		}
+	}
+
+	var results []SearchResult
+	for _, folder := range folders {
+		msgs, err := a.FolderMessages(folder)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			matches, ok := matchSearchTerms(msg, terms)
+			if !ok {
+				continue
+			}
+			results = append(results, SearchResult{
+				Folder:         folder,
+				MessageSummary: NewMessageSummary(msg),
+				Unread:         mailbox.IsUnread(msg),
+				Matches:        matches,
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Date.After(results[j].Date) })
+	return results, nil
+}
+
+// ParseSearchQuery splits the query into lower case search terms. Double quoted phrases are kept as a single term.
+func ParseSearchQuery(query string) []string {
+	var terms []string
+	for i, part := range strings.Split(query, `"`) {
+		if quoted := i%2 == 1; quoted {
+			if part = strings.TrimSpace(part); part != "" {
+				terms = append(terms, strings.ToLower(part))
+			}
+			continue
+		}
+		for _, term := range strings.FieldsFunc(part, unicode.IsSpace) {
+			terms = append(terms, strings.ToLower(term))
+		}
+	}
+	return terms
+}
+
+// matchSearchTerms reports whether all terms are found in the message, and in which fields.
+func matchSearchTerms(msg *fbb.Message, terms []string) ([]string, bool) {
+	fields := searchFields(msg)
+	var matches []string
+	for _, term := range terms {
+		var found bool
+		for _, f := range fields {
+			if !strings.Contains(f.text, term) {
+				continue
+			}
+			found = true
+			if !slices.Contains(matches, f.name) {
+				matches = append(matches, f.name)
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return matches, true
+}
+
+type searchField struct{ name, text string }
+
+func searchFields(msg *fbb.Message) []searchField {
+	addrs := func(addrs []fbb.Address) string {
+		var s []string
+		for _, a := range addrs {
+			s = append(s, a.Addr)
+		}
+		return strings.Join(s, " ")
+	}
+	body, _ := msg.Body()
+	fields := []searchField{
+		{SearchFieldSubject, msg.Subject()},
+		{SearchFieldFrom, msg.From().Addr},
+		{SearchFieldTo, addrs(msg.To())},
+		{SearchFieldCc, addrs(msg.Cc())},
+		{SearchFieldBody, body},
+	}
+	for _, f := range msg.Files() {
+		fields = append(fields, searchField{SearchFieldAttachment, f.Name()})
+		if !strings.EqualFold(filepath.Ext(f.Name()), ".xml") {
+			continue
+		}
+		_, vars, err := forms.ParseFormXML(f.Data())
+		if err != nil {
+			continue
+		}
+		values := make([]string, 0, len(vars))
+		for _, v := range vars {
+			values = append(values, html.UnescapeString(v))
+		}
+		fields = append(fields, searchField{SearchFieldForm, strings.Join(values, "\n")})
+	}
+	for i := range fields {
+		fields[i].text = strings.ToLower(fields[i].text)
+	}
+	return fields
+}
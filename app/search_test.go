@@ -0,0 +1,78 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	got := ParseSearchQuery(`Water  "Shelter 4" ICS213 ""`)
+	expect := []string{"water", "shelter 4", "ics213"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected %q, got %q", expect, got)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	a := newTestApp(t)
+
+	newMessage := func(subject, body string, date time.Time) *fbb.Message {
+		msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+		msg.AddTo("N0CALL")
+		msg.AddCc("EOC-1")
+		msg.SetSubject(subject)
+		msg.SetBody(body)
+		msg.SetDate(date)
+		return msg
+	}
+	now := time.Now().Truncate(time.Minute)
+
+	report := newMessage("Status report", "Nothing to report", now.Add(-time.Hour))
+	report.AddFile(fbb.NewFile("RMS_Express_Form_ICS213_Initial_Viewer.xml", []byte(`<?xml version="1.0"?>
+<RMS_Express_Form>
+  <form_parameters><display_form>ICS213_Initial_Viewer.html</display_form></form_parameters>
+  <variables><message>Water needed at Shelter 4 &amp; 5</message></variables>
+</RMS_Express_Form>`)))
+	if err := a.mbox.ProcessInbound(report); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.mbox.ProcessInbound(newMessage("Shelter 4 capacity", "Shelter 4 is full", now)); err != nil {
+		t.Fatal(err)
+	}
+	deleted := newMessage("Shelter 4 (old)", "", now)
+	if err := a.mbox.ProcessInbound(deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeleteMessage(deleted.MID(), "in"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := a.Search(`"shelter 4"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Subject != "Shelter 4 capacity" || !reflect.DeepEqual(results[0].Matches, []string{SearchFieldSubject, SearchFieldBody}) {
+		t.Errorf("unexpected first result: %+v", results[0])
+	}
+	if results[1].MID != report.MID() || results[1].Folder != "in" || !reflect.DeepEqual(results[1].Matches, []string{SearchFieldForm}) {
+		t.Errorf("unexpected second result: %+v", results[1])
+	}
+
+	results, err = a.Search("eoc-1 & ics213")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !reflect.DeepEqual(results[0].Matches, []string{SearchFieldCc, SearchFieldForm, SearchFieldAttachment}) {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	if results, _ := a.Search("shelter", TrashFolder); len(results) != 1 || results[0].MID != deleted.MID() {
+		t.Errorf("expected deleted message in trash: %+v", results)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/la5nta/pat/internal/email"
+	"github.com/la5nta/pat/internal/smtpd"
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+// SubmitEmail converts an internet message submitted by a local application to a Winlink message and posts it to the outbox.
+//
+// The message is sent from the callsign or tactical address of the From header if it is a Winlink
+// address (e.g. EOC-1@winlink.org), otherwise from this station's callsign. Envelope recipients not
+// listed in the To or Cc headers (e.g. blind copies) are added as To recipients, since Winlink
+// has no blind copies. Attachments are added with AddAttachment.
+func (a *App) SubmitEmail(rcpts []string, data []byte) (*fbb.Message, error) {
+	msg, err := email.ReadWith(bytes.NewReader(data), AddAttachment)
+	if err != nil {
+		return nil, err
+	}
+
+	// This is a new message: use a fresh MID and the time of submission.
+	from := a.options.MyCall
+	if addr := msg.From(); addr.Proto == "" && !addr.IsZero() {
+		from = addr.Addr
+	}
+	msg.Header.Set(fbb.HEADER_MID, fbb.GenerateMid(from))
+	msg.Header.Set(fbb.HEADER_MBO, a.options.MyCall)
+	msg.SetFrom(from)
+	msg.SetDate(time.Now())
+	msg.Header.Del(email.HeaderUnread)
+
+	// The threading headers of email clients are kept as a local reply link only (see SetInReplyTo).
+	if mid := InReplyTo(msg); mid != "" {
+		msg.Header.Set(headerReplyTo, mid)
+	}
+	msg.Header.Del(HeaderInReplyTo)
+	msg.Header.Del(HeaderReferences)
+
+	for _, rcpt := range rcpts {
+		addr := fbb.AddressFromString(rcpt)
+		if !slices.ContainsFunc(msg.Receivers(), func(r fbb.Address) bool { return strings.EqualFold(r.String(), addr.String()) }) {
+			msg.AddTo(addr.String())
+		}
+	}
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := a.mbox.AddOut(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// serveSMTP runs the SMTP submission server until the context is cancelled.
+func (a *App) serveSMTP(ctx context.Context) {
+	srv := &smtpd.Server{
+		Addr:     a.config.SMTP.Addr,
+		Hostname: strings.ToLower(a.options.MyCall),
+		Handler: func(from string, to []string, data []byte) error {
+			msg, err := a.SubmitEmail(to, data)
+			if err != nil {
+				log.Printf("SMTP: Rejected message from %s: %v", from, err)
+				return err
+			}
+			log.Printf("SMTP: Message %s from %s posted to outbox", msg.MID(), from)
+			return nil
+		},
+	}
+	log.Printf("SMTP server listening on %s", srv.Addr)
+	if err := srv.ListenAndServe(ctx); err != nil {
+		log.Printf("SMTP server failed: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/la5nta/pat/cfg"
+)
+
+func TestSubmitEmail(t *testing.T) {
+	a := newTestApp(t)
+	a.config = cfg.DefaultConfig
+
+	raw := "From: ICS software <ics@localhost>\n" +
+		"To: LA5NTA@winlink.org\n" +
+		"Cc: ops@example.com\n" +
+		"Subject: ICS-214 Activity log\n" +
+		"Message-ID: <ABCDEF123456@winlink.org>\n" +
+		"Content-Type: multipart/mixed; boundary=b\n" +
+		"\n" +
+		"--b\nContent-Type: text/plain\n\nActivity log attached.\n" +
+		"--b\nContent-Type: text/csv\nContent-Disposition: attachment; filename=log.csv\n\ntime,activity\n" +
+		"--b--\n"
+	msg, err := a.SubmitEmail([]string{"LA5NTA@winlink.org", "LA1B@winlink.org"}, []byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := a.mbox.Outbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].MID() != msg.MID() || msg.MID() == "ABCDEF123456" {
+		t.Fatalf("expected new message in outbox, got %v", out)
+	}
+	got := out[0]
+	if got.From().Addr != "N0CALL" || len(got.To()) != 2 || got.To()[1].Addr != "LA1B" || len(got.Cc()) != 1 {
+		t.Errorf("unexpected addresses: %v %v %v", got.From(), got.To(), got.Cc())
+	}
+	if body, _ := got.Body(); body != "Activity log attached.\r\n" || len(got.Files()) != 1 || got.Files()[0].Name() != "log.csv" {
+		t.Errorf("unexpected content: %q %v", body, got.Files())
+	}
+
+	// Tactical address.
+	raw = "From: EOC-1@winlink.org\nTo: LA5NTA@winlink.org\nSubject: Status\n\nAll good.\n"
+	if msg, err := a.SubmitEmail([]string{"LA5NTA@winlink.org"}, []byte(raw)); err != nil || msg.From().Addr != "EOC-1" {
+		t.Errorf("expected message from EOC-1, got %v (%v)", msg, err)
+	}
+
+	// Replies are linked locally, without threading headers.
+	raw = "From: ics@localhost\nTo: LA5NTA@winlink.org\nSubject: Re: Status\n" +
+		"In-Reply-To: <ABCDEF123456@winlink.org>\nReferences: <XYZ789@winlink.org> <ABCDEF123456@winlink.org>\n\nThanks.\n"
+	if msg, err := a.SubmitEmail([]string{"LA5NTA@winlink.org"}, []byte(raw)); err != nil {
+		t.Error(err)
+	} else if InReplyTo(msg) != "ABCDEF123456" || msg.Header.Get(HeaderInReplyTo) != "" || msg.Header.Get(HeaderReferences) != "" {
+		t.Errorf("unexpected threading headers: %v", msg.Header)
+	}
+
+	if _, err := a.SubmitEmail(nil, []byte("From: ics@localhost\nSubject: No recipients\n\n")); err == nil {
+		t.Error("expected message without recipients to be rejected")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+// Message headers used for threading.
+const (
+	HeaderInReplyTo  = "In-Reply-To"
+	HeaderReferences = "References"
+)
+
+// headerReplyTo links a composed reply to the original message. It is local metadata, removed
+// before the message is proposed, so replies don't spend airtime on threading headers.
+const headerReplyTo = "X-Pat-In-Reply-To"
+
+// replyPrefix matches (repeated) reply and forward prefixes of a subject line.
+var replyPrefix = regexp.MustCompile(`(?i)^\s*((re|fwd?|sv|vs|aw|wg)(\[\d+\])?\s*:\s*)+`)
+
+// InReplyTo returns the MID of the message the given message is a reply to, if known.
+func InReplyTo(msg *fbb.Message) string {
+	mid := msg.Header.Get(HeaderInReplyTo)
+	if mid == "" {
+		mid = msg.Header.Get(headerReplyTo)
+	}
+	return strings.Trim(strings.TrimSpace(mid), "<>")
+}
+
+// References returns the MIDs of previous messages in the conversation, if known.
+func References(msg *fbb.Message) []string {
+	var refs []string
+	for _, ref := range strings.Fields(msg.Header.Get(HeaderReferences)) {
+		if ref = strings.Trim(ref, "<>"); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// SetInReplyTo marks the given message as a reply to the original message.
+//
+// The link is kept in local metadata only (see headerReplyTo).
+func SetInReplyTo(msg *fbb.Message, original *fbb.Message) {
+	msg.Header.Set(headerReplyTo, original.MID())
+}
+
+// NormalizeSubject returns the subject without reply and forward prefixes (e.g. "Re: Fwd: Status" -> "Status").
+func NormalizeSubject(subject string) string {
+	return strings.TrimSpace(replyPrefix.ReplaceAllString(subject, ""))
+}
+
+// Thread is a conversation of related messages.
+type Thread struct {
+	ID           string          `json:"id"` // MID of the first message in the thread.
+	Subject      string          `json:"subject"`
+	Participants []string        `json:"participants"`
+	Count        int             `json:"count"`
+	Unread       int             `json:"unread"`
+	LastDate     time.Time       `json:"last_date"`
+	Messages     []ThreadMessage `json:"messages,omitempty"` // Sorted by date, oldest first.
+}
+
+// ThreadMessage is a message in a thread.
+type ThreadMessage struct {
+	Folder string `json:"folder"`
+	IndexedMessage
+}
+
+// Threads groups the messages in all folders except the outbox and trash into conversation threads, most recently active first.
+//
+// The messages are read from the mailbox index.
+//
+// Messages are related by the In-Reply-To and References headers (or the local reply link) where present. Otherwise,
+// replies and forwards are related to the most recent earlier message with the same normalized subject.
+func (a *App) Threads() ([]Thread, error) {
+	folders, err := a.folderNames()
+	if err != nil {
+		return nil, err
+	}
+	var msgs []ThreadMessage
+	for _, folder := range folders {
+		if folder == "out" || folder == TrashFolder {
+			continue
+		}
+		indexed, _, err := a.IndexedMessages(folder, MailboxQuery{})
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range indexed {
+			msgs = append(msgs, ThreadMessage{folder, m})
+		}
+	}
+	return BuildThreads(msgs), nil
+}
+
+// Thread returns the thread with the given ID.
+func (a *App) Thread(id string) (Thread, bool, error) {
+	threads, err := a.Threads()
+	if err != nil {
+		return Thread{}, false, err
+	}
+	for _, t := range threads {
+		if t.ID == id {
+			return t, true, nil
+		}
+	}
+	return Thread{}, false, nil
+}
+
+// BuildThreads groups the given messages into threads, most recently active first.
+func BuildThreads(msgs []ThreadMessage) []Thread {
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Date.Before(msgs[j].Date) })
+
+	// Union-find over message indices.
+	parent := make([]int, len(msgs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		if ri, rj := find(i), find(j); ri != rj {
+			parent[max(ri, rj)] = min(ri, rj) // The earliest message is the root.
+		}
+	}
+
+	byMID := make(map[string]int, len(msgs))
+	for i, m := range msgs {
+		if _, dup := byMID[m.MID]; !dup {
+			byMID[m.MID] = i
+		} else {
+			union(byMID[m.MID], i) // Same message in multiple folders.
+		}
+	}
+	bySubject := make(map[string]int) // Most recent message with the normalized subject.
+	for i, m := range msgs {
+		var related bool
+		for _, mid := range append([]string{m.InReplyTo}, m.References...) {
+			if j, ok := byMID[mid]; ok && mid != "" {
+				union(i, j)
+				related = true
+			}
+		}
+		subject := strings.ToLower(NormalizeSubject(m.Subject))
+		if j, ok := bySubject[subject]; ok && !related && subject != "" && replyPrefix.MatchString(m.Subject) {
+			union(i, j)
+		}
+		bySubject[subject] = i
+	}
+
+	groups := make(map[int][]ThreadMessage)
+	for i, m := range msgs {
+		root := find(i)
+		groups[root] = append(groups[root], m)
+	}
+	threads := make([]Thread, 0, len(groups))
+	for root, members := range groups {
+		t := Thread{
+			ID:       msgs[root].MID,
+			Subject:  NormalizeSubject(msgs[root].Subject),
+			Count:    len(members),
+			Messages: members,
+		}
+		for _, m := range members {
+			if m.Unread {
+				t.Unread++
+			}
+			if m.Date.After(t.LastDate) {
+				t.LastDate = m.Date
+			}
+			for _, addr := range append([]fbb.Address{m.From}, m.To...) {
+				if !slices.Contains(t.Participants, addr.Addr) {
+					t.Participants = append(t.Participants, addr.Addr)
+				}
+			}
+		}
+		threads = append(threads, t)
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		if !threads[i].LastDate.Equal(threads[j].LastDate) {
+			return threads[i].LastDate.After(threads[j].LastDate)
+		}
+		return threads[i].ID < threads[j].ID
+	})
+	return threads
+}
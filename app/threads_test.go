@@ -0,0 +1,100 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+func TestNormalizeSubject(t *testing.T) {
+	tests := map[string]string{
+		"Status":                "Status",
+		"Re: Status":            "Status",
+		"RE:Re: Fwd: Status":    "Status",
+		"  fw: SV: ICS-213 ":    "ICS-213",
+		"Re[2]: Shelter 4":      "Shelter 4",
+		"Regarding: the report": "Regarding: the report",
+	}
+	for subject, expect := range tests {
+		if got := NormalizeSubject(subject); got != expect {
+			t.Errorf("%q: expected %q, got %q", subject, expect, got)
+		}
+	}
+}
+
+func TestThreads(t *testing.T) {
+	a := newTestApp(t)
+
+	now := time.Now().Truncate(time.Minute)
+	newMessage := func(from, to, subject string, age time.Duration) *fbb.Message {
+		msg := fbb.NewMessage(fbb.Private, from)
+		msg.AddTo(to)
+		msg.SetSubject(subject)
+		msg.SetBody("Body")
+		msg.SetDate(now.Add(-age))
+		return msg
+	}
+
+	// A form and its acknowledgement, related by headers (different subjects).
+	form := newMessage("LA5NTA", "N0CALL", "ICS-213 Resource request", 5*time.Hour)
+	ack := newMessage("N0CALL", "LA5NTA", "Acknowledged", 4*time.Hour)
+	SetInReplyTo(ack, form)
+	if ack.Header.Get(HeaderInReplyTo) != "" || ack.Header.Get(HeaderReferences) != "" {
+		t.Error("expected reply link to be local metadata only")
+	}
+	// A conversation related by subject only.
+	status := newMessage("LA1B", "N0CALL", "Status", 3*time.Hour)
+	statusReply := newMessage("N0CALL", "LA1B", "Re: Status", 2*time.Hour)
+	// Unrelated messages with identical subjects are not grouped.
+	daily := newMessage("LA3F", "N0CALL", "Daily report", time.Hour)
+	daily2 := newMessage("LA3F", "N0CALL", "Daily report", 0)
+
+	for _, msg := range []*fbb.Message{form, status, daily, daily2} {
+		if err := a.mbox.ProcessInbound(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, msg := range []*fbb.Message{ack, statusReply} {
+		if err := a.mbox.AddOut(msg); err != nil {
+			t.Fatal(err)
+		}
+		a.mbox.SetSent(msg.MID(), false)
+	}
+	if err := a.MoveMessage(form.MID(), "in", "archive"); err != nil {
+		t.Fatal(err)
+	}
+
+	threads, err := a.Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 4 {
+		t.Fatalf("expected 4 threads, got %d: %+v", len(threads), threads)
+	}
+	if threads[0].ID != daily2.MID() || threads[1].ID != daily.MID() {
+		t.Errorf("expected daily reports as separate threads, got %s and %s", threads[0].ID, threads[1].ID)
+	}
+	if s := threads[2]; s.ID != status.MID() || s.Count != 2 || s.Subject != "Status" || s.Messages[1].Folder != "sent" {
+		t.Errorf("unexpected status thread: %+v", s)
+	}
+	f := threads[3]
+	if f.ID != form.MID() || f.Count != 2 || f.Unread != 1 || f.Messages[0].Folder != "archive" || f.Messages[1].MID != ack.MID() {
+		t.Errorf("unexpected form thread: %+v", f)
+	}
+
+	// The reply link is not proposed.
+	outbound := newMessage("N0CALL", "LA5NTA", "Acknowledged", 0)
+	SetInReplyTo(outbound, form)
+	eligibleOutbound([]*fbb.Message{outbound}, now)
+	if InReplyTo(outbound) != "" {
+		t.Error("expected reply link to be removed before proposing")
+	}
+
+	if _, ok, err := a.Thread(status.MID()); !ok || err != nil {
+		t.Errorf("expected thread %s to be found (%v)", status.MID(), err)
+	}
+	if _, ok, _ := a.Thread(statusReply.MID()); ok {
+		t.Error("expected thread lookup by reply MID to fail")
+	}
+}
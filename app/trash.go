@@ -0,0 +1,167 @@
+// Copyright 2016 Martin Hebnes Pedersen (LA5NTA). All rights reserved.
+// Use of this source code is governed by the MIT-license that can be
+// found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/la5nta/pat/internal/debug"
+	"github.com/la5nta/wl2k-go/fbb"
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+// TrashFolder is the mailbox folder holding deleted messages until they are restored or purged.
+const TrashFolder = "trash"
+
+const (
+	headerTrashed     = "X-Pat-Trashed"
+	headerTrashedFrom = "X-Pat-Trashed-From"
+)
+
+const trashPurgeInterval = time.Hour
+
+// TrashedAt returns the time the given message was moved to the trash.
+func TrashedAt(msg *fbb.Message) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, msg.Header.Get(headerTrashed))
+	return t, err == nil
+}
+
+// TrashedFrom returns the folder the given message was deleted from.
+func TrashedFrom(msg *fbb.Message) string { return msg.Header.Get(headerTrashedFrom) }
+
+// DeleteMessage moves the message with the given MID to the trash.
+//
+// Messages deleted from the trash are removed permanently.
+func (a *App) DeleteMessage(mid, folder string) error {
+	file, err := a.messageFile(folder, mid)
+	if err != nil {
+		return err
+	}
+	if folder == TrashFolder {
+		return os.Remove(file)
+	}
+
+	msg, err := mailbox.OpenMessage(file)
+	if err != nil {
+		return err
+	}
+	msg.Header.Del("X-FilePath")
+	msg.Header.Set(headerTrashed, time.Now().UTC().Format(time.RFC3339))
+	msg.Header.Set(headerTrashedFrom, folder)
+
+	dir, _ := a.folderPath(TrashFolder)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeMessageFile(filepath.Join(dir, filepath.Base(file)), msg); err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// RestoreMessage moves the message with the given MID from the trash back to the folder it was deleted from.
+//
+// The folder is re-created if it no longer exists. The name of the folder is returned.
+func (a *App) RestoreMessage(mid string) (string, error) {
+	file, err := a.messageFile(TrashFolder, mid)
+	if err != nil {
+		return "", err
+	}
+	msg, err := mailbox.OpenMessage(file)
+	if err != nil {
+		return "", err
+	}
+	folder := TrashedFrom(msg)
+	if folder == "" || folder == TrashFolder {
+		folder = "in"
+	}
+	dir, err := a.folderPath(folder)
+	if err != nil {
+		return "", err
+	}
+	msg.Header.Del("X-FilePath")
+	msg.Header.Del(headerTrashed)
+	msg.Header.Del(headerTrashedFrom)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := writeMessageFile(filepath.Join(dir, filepath.Base(file)), msg); err != nil {
+		return "", err
+	}
+	return folder, os.Remove(file)
+}
+
+// EmptyTrash permanently removes all messages in the trash, returning the number of messages removed.
+func (a *App) EmptyTrash() (int, error) { return a.PurgeTrash(0) }
+
+// PurgeTrash permanently removes messages that have been in the trash for longer than maxAge.
+//
+// Messages missing the trashed timestamp are aged by file modification time.
+func (a *App) PurgeTrash(maxAge time.Duration) (int, error) {
+	dir, _ := a.folderPath(TrashFolder)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != mailbox.Ext {
+			continue
+		}
+		file := filepath.Join(dir, e.Name())
+		if maxAge > 0 && time.Since(trashedTime(file, e)) < maxAge {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func trashedTime(file string, e os.DirEntry) time.Time {
+	if msg, err := mailbox.OpenMessage(file); err == nil {
+		if t, ok := TrashedAt(msg); ok {
+			return t
+		}
+	}
+	if fi, err := e.Info(); err == nil {
+		return fi.ModTime()
+	}
+	return time.Now()
+}
+
+func (a *App) purgeTrash() {
+	if a.config.TrashPurgeDays <= 0 {
+		return
+	}
+	n, err := a.PurgeTrash(time.Duration(a.config.TrashPurgeDays) * 24 * time.Hour)
+	if err != nil {
+		log.Printf("Unable to purge trash: %v", err)
+	} else if n > 0 {
+		debug.Printf("Purged %d message(s) from trash", n)
+	}
+}
+
+// trashPurger purges old messages from the trash periodically until the context is cancelled.
+func (a *App) trashPurger(ctx context.Context) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.purgeTrash()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/la5nta/wl2k-go/mailbox"
+)
+
+func TestTrash(t *testing.T) {
+	a := newTestApp(t)
+	if err := a.CreateFolder("eoc"); err != nil {
+		t.Fatal(err)
+	}
+
+	var mids []string
+	for _, msg := range addInbound(t, a, "One", "Two") {
+		mids = append(mids, msg.MID())
+	}
+	if err := a.MoveMessage(mids[1], "in", "eoc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.DeleteMessage(mids[0], "in"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeleteMessage(mids[1], "eoc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeleteFolder("eoc"); err != nil {
+		t.Fatal(err)
+	}
+	trash, err := a.FolderMessages(TrashFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 2 {
+		t.Fatalf("expected 2 messages in trash, got %d", len(trash))
+	}
+	for _, msg := range trash {
+		if _, ok := TrashedAt(msg); !ok {
+			t.Errorf("missing trashed timestamp for %s", msg.MID())
+		}
+	}
+
+	// Restore re-creates the deleted folder and preserves the unread flag.
+	folder, err := a.RestoreMessage(mids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := mailbox.OpenMessage(filepath.Join(a.mbox.MBoxPath, "eoc", mids[1]+mailbox.Ext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if folder != "eoc" || TrashedFrom(restored) != "" || !mailbox.IsUnread(restored) {
+		t.Errorf("unexpected restored message in %s: %v", folder, restored.Header)
+	}
+
+	if n, err := a.PurgeTrash(time.Hour); err != nil || n != 0 {
+		t.Errorf("expected nothing to purge, got %d (%v)", n, err)
+	}
+	if n, err := a.EmptyTrash(); err != nil || n != 1 {
+		t.Errorf("expected 1 purged message, got %d (%v)", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(a.mbox.MBoxPath, "in", mids[0]+mailbox.Ext)); !os.IsNotExist(err) {
+		t.Errorf("expected message to be gone from inbox: %v", err)
+	}
+}
@@ -65,15 +65,46 @@ var Commands = []app.Command{
 			"--attachment , -a": "Attachment path (may be repeated)",
 			"--cc, -c":          "CC Address(es) (may be repeated)",
 			"--p2p-only":        "Send over peer to peer links only (avoid CMS)",
+			"--hold":            "Keep the message in the outbox until released (see mailbox release)",
+			"--priority":        "Outbox priority. Messages with a higher priority are sent first (default 0)",
+			"--send-after":      "Don't send before the given time (RFC3339, YYYY-MM-DD HH:MM or duration like 2h)",
 			"":                  "Recipient address (may be repeated)",
 		},
 		HandleFunc: ComposeMessage,
 	},
 	{
-		Str:        "read",
-		Desc:       "Read messages.",
+		Str:  "read",
+		Desc: "Read messages.",
+		Options: map[string]string{
+			"--threads, -t": "List conversation threads across all folders instead of folder contents.",
+		},
 		HandleFunc: ReadHandle,
 	},
+	{
+		Str:        "mailbox",
+		Desc:       "Manage mailbox folders and the trash, and export/import regular email.",
+		Usage:      MailboxUsage,
+		Example:    MailboxExample,
+		HandleFunc: MailboxHandle,
+	},
+	{
+		Str:        "contacts",
+		Desc:       "Manage the address book of contacts and groups.",
+		Usage:      ContactsUsage,
+		Example:    ContactsExample,
+		HandleFunc: ContactsHandle,
+	},
+	{
+		Str:   "search",
+		Desc:  "Search messages in all mailbox folders.",
+		Usage: SearchUsage,
+		Options: map[string]string{
+			"--folder":     "Only search the given folder (may be repeated).",
+			"--format, -f": "Output format: table (default) or json.",
+		},
+		Example:    SearchExample,
+		HandleFunc: SearchHandle,
+	},
 	{
 		Str:     "composeform",
 		Aliases: []string{"formPath"},
@@ -118,9 +149,26 @@ var Commands = []app.Command{
 			"--force-download, -d":    "Force download of latest list from winlink.org.",
 			"--sort-distance, -s":     "Sort by distance",
 			"--sort-link-quality, -q": "Sort by predicted link quality (requires VOACAP)",
+			"--sort-history":          "Sort by success rate and throughput of previous connections (from the event log)",
 		},
 		HandleFunc: RMSListHandle,
 	},
+	{
+		Str:   "log",
+		Desc:  "Query the event log (connects and exchanges).",
+		Usage: LogUsage,
+		Options: map[string]string{
+			"--since":      "Only events at or after the given time.",
+			"--until":      "Only events before the given time.",
+			"--type, -t":   "Event type filter (connect or exchange).",
+			"--target":     "Remote station callsign filter.",
+			"--transport":  "Transport filter (e.g. ardop, varahf or telnet).",
+			"--success":    "Success filter (true or false).",
+			"--format, -f": "Output format: table (default), json or csv.",
+		},
+		Example:    LogExample,
+		HandleFunc: LogHandle,
+	},
 	{
 		Str:  "updateforms",
 		Desc: "Download the latest form templates. (DEPRECATED)",
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/la5nta/pat/app"
 	"github.com/la5nta/pat/internal/editor"
@@ -30,6 +31,10 @@ type composerFlags struct {
 	subject string
 	p2pOnly bool
 
+	// Outbox metadata
+	outbox    app.OutboxMeta
+	sendAfter string
+
 	body string
 
 	attachmentPaths []string
@@ -39,6 +44,8 @@ type composerFlags struct {
 	forward   string // path/mid
 	inReplyTo string // path/mid
 	replyAll  bool
+
+	original *fbb.Message // the message replied to (if any)
 }
 
 func ComposeMessage(ctx context.Context, app *app.App, args []string) {
@@ -55,7 +62,13 @@ func ComposeMessage(ctx context.Context, app *app.App, args []string) {
 	set.StringVarP(&flags.inReplyTo, "in-reply-to", "", "", "")
 	set.StringVarP(&flags.forward, "forward", "", "", "")
 	set.BoolVarP(&flags.replyAll, "reply-all", "", false, "")
+	set.BoolVarP(&flags.outbox.Hold, "hold", "", false, "")
+	set.IntVarP(&flags.outbox.Priority, "priority", "", 0, "")
+	set.StringVarP(&flags.sendAfter, "send-after", "", "", "")
 	set.Parse(args)
+	if flags.sendAfter != "" {
+		flags.outbox.SendAfter = mustParseSendAfter(flags.sendAfter)
+	}
 	// Remaining args are recipients
 	for _, r := range set.Args() {
 		if strings.TrimSpace(r) == "" { // Filter out empty args (this actually happens)
@@ -84,6 +97,8 @@ func composeMessage(app *app.App, flags composerFlags, interactive bool) {
 	if interactive {
 		promptHeader(&flags)
 	}
+	// Resolve address book groups and contact names
+	flags.to, flags.cc = app.ExpandRecipients(flags.to), app.ExpandRecipients(flags.cc)
 
 	if err := buildBody(app, &flags, interactive); err != nil {
 		log.Fatal(err)
@@ -108,6 +123,7 @@ func prepareReply(app *app.App, flags *composerFlags) error {
 	if flags.subject == "" {
 		flags.subject = "Re: " + strings.TrimSpace(strings.TrimPrefix(originalMsg.Subject(), "Re:"))
 	}
+	flags.original = originalMsg
 	flags.to = append(flags.to, originalMsg.From().String())
 	if flags.replyAll {
 		for _, addr := range append(originalMsg.To(), originalMsg.Cc()...) {
@@ -261,6 +277,10 @@ func buildMessage(mycall string, flags composerFlags) *fbb.Message {
 	if flags.p2pOnly {
 		msg.Header.Set("X-P2POnly", "true")
 	}
+	if flags.original != nil {
+		app.SetInReplyTo(msg, flags.original)
+	}
+	app.SetOutboxMeta(msg, flags.outbox)
 
 	return msg
 }
@@ -279,6 +299,9 @@ func previewAndPromptConfirmation(flags *composerFlags) (ok bool) {
 		fmt.Println("From:", flags.from)
 		fmt.Println("Subject:", flags.subject)
 		fmt.Println("Attachments:", strings.Join(attachments, ", "))
+		if flags.outbox != (app.OutboxMeta{}) {
+			fmt.Println("Outbox:", flags.outbox)
+		}
 		fmt.Println("================================================================")
 		fmt.Println(flags.body)
 		fmt.Println("================================================================")
@@ -305,6 +328,14 @@ func previewAndPromptConfirmation(flags *composerFlags) (ok bool) {
 	}
 }
 
+func mustParseSendAfter(str string) time.Time {
+	t, err := app.ParseSendAfter(str, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return t
+}
+
 func composeBody(template string) (string, error) {
 	body, err := editor.EditText(template)
 	if err != nil {
@@ -15,13 +15,21 @@ func ConnectHandle(_ context.Context, app *app.App, args []string) {
 
 	app.PromptHub().AddPrompter(TerminalPrompter{})
 
-	if success := app.Connect(args[0]); !success {
+	if success := app.ConnectChain(args...); !success {
 		os.Exit(1)
 	}
 }
 
 const (
-	UsageConnect = `'alias' or 'transport://[host][/digi]/targetcall[?params...]'
+	UsageConnect = `'alias' or 'transport://[host][/digi]/targetcall[?params...]' ...
+
+  If more than one alias or URL is given, each is attempted in turn until a session succeeds.
+  Connect chains (connect_chains in config) are resolved the same way.
+
+auto:
+  'auto:mode[?band=&prefix=&max=]' selects the best RMS gateways from the RMS list (see rmslist),
+   ranked by predicted link quality (or distance without prediction engine), and attempts the top
+   candidates in turn (max defaults to 3).
 
 transport:
   telnet:          TCP/IP
@@ -66,5 +74,7 @@ params:
   connect pactor:///LA3F               Connect to RMS HF Gateway LA3F using PACTOR.
   connect varahf:///LA1B               Connect to RMS HF Gateway LA1B using VARA HF TNC.
   connect varafm:///LA5NTA             Connect to LA5NTA using VARA FM TNC.
+  connect auto:varahf?band=40m&max=5   Connect to the best of the five top ranked VARA HF gateways on 40m.
+  connect varahf:///LA1B telnet        Connect to LA1B using VARA HF TNC, falling back to telnet if the session fails.
 `
 )
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/la5nta/pat/app"
+	"github.com/spf13/pflag"
+)
+
+const (
+	ContactsUsage = `subcommand [args]
+
+subcommands:
+  list [QUERY]             List contacts, optionally only those with an address or name containing QUERY.
+  add [options] ADDRESS    Add a contact. ADDRESS is a callsign, a tactical address or an SMTP address.
+      --name NAME              Name usable as a recipient when composing messages.
+      --notes TEXT             Free text notes.
+  edit [options] ADDRESS   Change the name or notes of a contact (same options as add).
+  remove ADDRESS           Remove a contact.
+  groups                   List groups (distribution lists) and their members.
+  group NAME MEMBER...     Create or replace a group. Members are addresses, contact names or other groups.
+  ungroup NAME             Remove a group.
+  harvest                  Add the correspondents of all messages in the inbox and sent folders.
+
+  Groups and contact names given as recipients (e.g. with compose, or in the web GUI) are replaced by the
+  corresponding addresses when the message is posted.
+  Correspondents of sent and received messages are added automatically unless contact_harvesting_disabled is
+  set (see configure). The address book is stored as contacts.json beside the config file.`
+
+	ContactsExample = `
+  add --name "County EOC" EOC-1      Add the tactical address EOC-1.
+  add ops@example.com                Add an SMTP address.
+  group liaisons LA5NTA "County EOC" Create the group liaisons.
+  list la5                           List contacts matching la5.`
+)
+
+func ContactsHandle(ctx context.Context, a *app.App, args []string) {
+	cmd, args := shiftArgs(args)
+	nArgs := map[string]int{"list": -1, "add": -1, "edit": -1, "remove": 1, "groups": 0, "group": -1, "ungroup": 1, "harvest": 0}
+	if n, ok := nArgs[cmd]; !ok || (n >= 0 && len(args) != n) {
+		fmt.Println("Invalid arguments, try 'contacts help'.")
+		os.Exit(1)
+	}
+
+	book := a.AddressBook()
+	var err error
+	switch cmd {
+	case "list":
+		err = contactsListHandle(book, strings.Join(args, " "))
+	case "add", "edit":
+		err = contactsPutHandle(book, cmd, args)
+	case "remove":
+		err = book.DeleteContact(args[0])
+	case "groups":
+		var groups []app.ContactGroup
+		if groups, err = book.Groups(); err == nil {
+			for _, g := range groups {
+				fmt.Printf("%-20s %s\n", g.Name, strings.Join(g.Members, ", "))
+			}
+		}
+	case "group":
+		if len(args) < 2 {
+			err = fmt.Errorf("a group must have at least one member")
+			break
+		}
+		err = book.PutGroup(app.ContactGroup{Name: args[0], Members: args[1:]})
+	case "ungroup":
+		err = book.DeleteGroup(args[0])
+	case "harvest":
+		var n int
+		if n, err = a.HarvestMailbox(); err == nil {
+			fmt.Printf("%d contact(s) added.\n", n)
+		}
+	}
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+func contactsListHandle(book *app.AddressBook, query string) error {
+	contacts, err := book.Contacts(query)
+	if err != nil {
+		return err
+	}
+	fmtStr := "%-30s %-9s %-25s %-16s %s\n"
+	fmt.Printf(fmtStr, "address", "kind", "name", "last seen", "notes")
+	for _, c := range contacts {
+		var lastSeen string
+		if !c.LastSeen.IsZero() {
+			lastSeen = c.LastSeen.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf(fmtStr, c.Address, c.Kind, c.Name, lastSeen, c.Notes)
+	}
+	return nil
+}
+
+func contactsPutHandle(book *app.AddressBook, cmd string, args []string) error {
+	var c app.Contact
+	set := pflag.NewFlagSet("contacts "+cmd, pflag.ExitOnError)
+	set.StringVar(&c.Name, "name", "", "")
+	set.StringVar(&c.Notes, "notes", "", "")
+	set.Parse(args)
+	if set.NArg() != 1 {
+		return fmt.Errorf("missing address")
+	}
+	c.Address = set.Arg(0)
+	if cmd == "add" {
+		_, err := book.AddContact(c)
+		return err
+	}
+	current, err := book.Contact(c.Address)
+	if err != nil {
+		return err
+	}
+	if !set.Changed("name") {
+		c.Name = current.Name
+	}
+	if !set.Changed("notes") {
+		c.Notes = current.Notes
+	}
+	_, err = book.UpdateContact(c.Address, c)
+	return err
+}
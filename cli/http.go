@@ -26,8 +26,6 @@ func HTTPHandle(ctx context.Context, a *app.App, args []string) {
 		os.Exit(1)
 	}
 
-	scheduleLoop(ctx, a)
-
 	if err := api.ListenAndServe(ctx, a, addr); err != nil {
 		log.Println(err)
 	}
@@ -7,13 +7,11 @@ import (
 	"log"
 	"os"
 	"runtime"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/la5nta/pat/api"
 	"github.com/la5nta/pat/app"
-	"github.com/la5nta/wl2k-go/rigcontrol/hamlib"
 	"github.com/peterh/liner"
 
 	"github.com/spf13/pflag"
@@ -45,8 +43,6 @@ func InteractiveHandle(ctx context.Context, a *app.App, args []string) {
 }
 
 func Interactive(ctx context.Context, a *app.App) {
-	scheduleLoop(ctx, a)
-
 	line := liner.NewLiner()
 	defer line.Close()
 
@@ -84,17 +80,21 @@ func execCmd(a *app.App, line string) (quit bool) {
 			return
 		}
 
-		a.Connect(param)
+		a.ConnectChain(strings.Fields(param)...)
 	case "listen":
 		a.Listen(param)
 	case "unlisten":
 		a.Unlisten(param)
 	case "heard":
-		PrintHeard(a)
+		a.WriteHeard(os.Stdout)
 	case "freq":
-		freq(a, param)
+		if err := a.Freq(os.Stdout, param); err != nil {
+			log.Println(err)
+		}
 	case "qtc":
-		PrintQTC(a)
+		if err := a.WriteQTC(os.Stdout); err != nil {
+			log.Println(err)
+		}
 	case "debug":
 		os.Setenv("ardop_debug", "1")
 		fmt.Println("Number of goroutines:", runtime.NumGoroutine())
@@ -122,7 +122,7 @@ func printInteractiveUsage() {
 	fmt.Println("Transports:", strings.Join(transports, ", "))
 
 	cmds := []string{
-		"connect  <connect-url or alias>  Connect to a remote station.",
+		"connect  <connect-url or alias>  Connect to a remote station (more are attempted in turn).",
 		"listen   <transport>             Listen for incoming connections.",
 		"unlisten <transport>             Unregister listener for incoming connections.",
 		"freq     <transport>[:<freq>]    Read/set rig frequency.",
@@ -146,82 +146,6 @@ func getPrompt(a *app.App) string {
 	return buf.String()
 }
 
-func PrintHeard(a *app.App) {
-	pf := func(call string, t time.Time) {
-		fmt.Printf("  %-10s (%s)\n", call, t.Format(time.RFC1123))
-	}
-
-	for method, heard := range a.Heard() {
-		fmt.Printf("%s:\n", method)
-		for _, v := range heard {
-			pf(v.Callsign, v.Time)
-		}
-	}
-}
-
-func PrintQTC(a *app.App) {
-	msgs, err := a.Mailbox().Outbox()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	fmt.Printf("QTC: %d.\n", len(msgs))
-	for _, msg := range msgs {
-		fmt.Printf(`%-12.12s (%s): %s`, msg.MID(), msg.Subject(), fmt.Sprint(msg.To()))
-		if msg.Header.Get("X-P2POnly") == "true" {
-			fmt.Printf(" (P2P only)")
-		}
-		fmt.Println("")
-	}
-}
-
-func freq(a *app.App, param string) {
-	parts := strings.SplitN(param, ":", 2)
-	if parts[0] == "" {
-		fmt.Println("Missing transport parameter.")
-		fmt.Println("Syntax: freq <transport>[:<frequency>]")
-		return
-	}
-
-	rig, rigName, ok, err := a.VFOForTransport(parts[0])
-	if err != nil {
-		log.Println(err)
-		return
-	} else if !ok {
-		log.Printf("Rig '%s' not loaded.", rigName)
-		return
-	}
-
-	if len(parts) < 2 {
-		freq, err := rig.GetFreq()
-		if err != nil {
-			log.Printf("Unable to get frequency: %s", err)
-		}
-		fmt.Printf("%.3f\n", float64(freq)/1e3)
-		return
-	}
-
-	if _, _, err := setFreq(rig, parts[1]); err != nil {
-		log.Printf("Unable to set frequency: %s", err)
-	}
-}
-
-func setFreq(rig hamlib.VFO, freq string) (newFreq, oldFreq int, err error) {
-	oldFreq, err = rig.GetFreq()
-	if err != nil {
-		return 0, 0, fmt.Errorf("unable to get rig frequency: %w", err)
-	}
-
-	f, err := strconv.ParseFloat(freq, 64)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	newFreq = int(f * 1e3)
-	err = rig.SetFreq(newFreq)
-	return
-}
-
 func parseCommand(str string) (mode, param string) {
 	parts := strings.SplitN(str, " ", 2)
 	if len(parts) == 1 {
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bndr/gotabulate"
+	"github.com/la5nta/pat/app"
+	"github.com/spf13/pflag"
+)
+
+const (
+	LogUsage = `[options]
+	log export --adif [options]
+
+  Print records from the event log, optionally filtered.
+  Times are given as RFC3339, YYYY-MM-DD or relative to now (e.g. 36h or 7d).
+
+  The export subcommand writes successful radio sessions as ADIF QSO records
+  for import into logbook software. Sessions over telnet are omitted.
+`
+	LogExample = `
+  log --since 7d --type connect --transport ardop --success=false   Failed ARDOP connects the last week.
+  log --target LA1B --format csv > la1b.csv                          Export all sessions with LA1B as CSV.
+  log export --adif --since 2024-01-01 -o winlink.adi                Export this year's radio sessions as ADIF.
+`
+)
+
+func LogHandle(ctx context.Context, a *app.App, args []string) {
+	if len(args) > 0 && args[0] == "export" {
+		logExportHandle(ctx, a, args[1:])
+		return
+	}
+
+	cancel := exitOnContextCancellation(ctx)
+	defer cancel()
+
+	var filter app.EventFilter
+	var since, until, success, format string
+	set := pflag.NewFlagSet("log", pflag.ExitOnError)
+	set.StringVar(&since, "since", "", "")
+	set.StringVar(&until, "until", "", "")
+	set.StringVarP(&filter.What, "type", "t", "", "")
+	set.StringVar(&filter.Target, "target", "", "")
+	set.StringVar(&filter.Transport, "transport", "", "")
+	set.StringVar(&success, "success", "", "")
+	set.StringVarP(&format, "format", "f", "table", "")
+	set.Parse(args)
+
+	filter, err := parseEventFilter(filter, since, until, success)
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(1)
+	}
+
+	events, err := a.Events(filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(events)
+	case "csv":
+		err = app.WriteEventsCSV(os.Stdout, events)
+	case "table":
+		printEvents(events)
+	default:
+		err = fmt.Errorf("unsupported format '%s'", format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func logExportHandle(ctx context.Context, a *app.App, args []string) {
+	cancel := exitOnContextCancellation(ctx)
+	defer cancel()
+
+	var filter app.EventFilter
+	var since, until, output string
+	var adif bool
+	set := pflag.NewFlagSet("export", pflag.ExitOnError)
+	set.BoolVar(&adif, "adif", false, "")
+	set.StringVarP(&output, "output", "o", "", "")
+	set.StringVar(&since, "since", "", "")
+	set.StringVar(&until, "until", "", "")
+	set.StringVar(&filter.Target, "target", "", "")
+	set.StringVar(&filter.Transport, "transport", "", "")
+	set.Parse(args)
+
+	if !adif {
+		fmt.Println("ERROR: missing export format (--adif)")
+		os.Exit(1)
+	}
+	filter, err := parseEventFilter(filter, since, until, "")
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(1)
+	}
+
+	// Sessions are paired with their connect event, so the filter is applied afterwards.
+	events, err := a.Events(app.EventFilter{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	records := app.ADIFRecords(events, filter)
+
+	w := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := app.WriteADIF(w, records); err != nil {
+		log.Fatal(err)
+	}
+	if output != "" {
+		fmt.Printf("Exported %d session(s) to %s.\n", len(records), output)
+	}
+}
+
+func parseEventFilter(filter app.EventFilter, since, until, success string) (app.EventFilter, error) {
+	var err error
+	now := time.Now()
+	if since != "" {
+		if filter.Since, err = app.ParseEventTime(since, now); err != nil {
+			return filter, err
+		}
+	}
+	if until != "" {
+		if filter.Until, err = app.ParseEventTime(until, now); err != nil {
+			return filter, err
+		}
+	}
+	if success != "" {
+		v, err := strconv.ParseBool(success)
+		if err != nil {
+			return filter, fmt.Errorf("invalid success value '%s'", success)
+		}
+		filter.Success = &v
+	}
+	return filter, nil
+}
+
+func printEvents(events []app.Event) {
+	if len(events) == 0 {
+		fmt.Println("(no events)")
+		return
+	}
+	rows := make([][]string, len(events))
+	nFailed := 0
+	for i, e := range events {
+		status := "OK"
+		if !e.Success {
+			status = "FAILED"
+			nFailed++
+		}
+		details := e.Error
+		if e.What == app.EventExchange && e.Success {
+			details = fmt.Sprintf("%d sent, %d received in %s", len(e.Sent), len(e.Received), e.Duration())
+		}
+		var freq string
+		if e.Freq > 0 {
+			freq = e.Freq.String()
+		}
+		rows[i] = []string{
+			e.LogTime.Local().Format(time.DateTime),
+			e.What,
+			e.Target(),
+			e.Transport(),
+			freq,
+			status,
+			details,
+		}
+	}
+	t := gotabulate.Create(rows)
+	t.SetHeaders([]string{"Time", "Event", "Target", "Transport", "Freq", "Status", "Details"})
+	t.SetAlign("left")
+	t.SetWrapStrings(true)
+	t.SetMaxCellSize(60)
+	fmt.Println(t.Render("simple"))
+	fmt.Printf("%d event(s), %d failed.\n", len(events), nFailed)
+}
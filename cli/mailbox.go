@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/la5nta/pat/app"
+	"github.com/spf13/pflag"
+)
+
+const (
+	MailboxUsage = `subcommand [args]
+
+subcommands:
+  list                     List mailbox folders with message counts.
+  create NAME              Create a new folder.
+  rename NAME NEW_NAME     Rename a folder.
+  delete NAME              Delete an empty folder.
+  move MID FOLDER TARGET   Move a message from one folder to another.
+  trash MID FOLDER         Move a message to the trash (or delete it permanently if already in trash).
+  restore MID              Restore a message from the trash to the folder it was deleted from.
+  empty-trash              Permanently delete all messages in the trash.
+  hold MID                 Hold an outbox message. Held messages are not sent until released.
+  release MID              Release a held outbox message.
+  schedule [options] MID   Set the sending priority and time of an outbox message.
+      --priority N             Messages with a higher priority are sent first (default: unchanged).
+      --send-after TIME        Don't send before TIME (RFC3339, YYYY-MM-DD HH:MM or a duration like 2h).
+                               An empty TIME clears it.
+  export [options] DIR     Export messages as regular email to the (empty or new) directory DIR.
+      --format FORMAT          eml (default), mbox, maildir or express (Winlink Express MID.mime folders).
+      --folder NAME            Only export the given folder (may be repeated). Defaults to all but the trash.
+  import [options] PATH    Import regular email from an .eml file, a directory of .eml files, an mbox file or a Maildir,
+                           or Winlink Express messages from a .mime file or folder.
+      --format FORMAT          eml, mbox, maildir or express. Detected from PATH by default.
+      --folder NAME            Destination folder (default: in). When importing a Winlink Express messages
+                               directory, each folder (Inbox, Outbox, Sent Items, ...) goes to the
+                               corresponding Pat folder by default.
+
+  The built-in folders (in, out, sent, archive and trash) can not be renamed or deleted.
+  Exported messages keep their MID, date, read state and P2P-only flag, and attachments (including forms) become MIME parts.
+  Callsigns are addressed as CALL@winlink.org. Messages already in the destination folder are not re-imported.
+  Messages are purged from the trash automatically after trash_purge_days (see configure).`
+
+	MailboxExample = `
+  create eoc               Create a folder named eoc.
+  move ABCDEF123456 in eoc Move message ABCDEF123456 from the inbox to eoc.
+  rename eoc ops           Rename the eoc folder to ops.
+  restore ABCDEF123456     Undo deletion of message ABCDEF123456.
+  schedule --priority 10 --send-after "2026-06-01 08:00" ABCDEF123456
+                           Send message ABCDEF123456 first, but not before 08:00 on June 1st.
+  export --format mbox --folder eoc ~/handover
+                           Export the eoc folder to ~/handover/eoc.mbox.
+  import --folder eoc ~/Maildir
+                           Import all messages in ~/Maildir to the eoc folder.
+  export --format express /media/usb/messages
+                           Export all folders for a Winlink Express station.`
+)
+
+func MailboxHandle(ctx context.Context, a *app.App, args []string) {
+	cmd, args := shiftArgs(args)
+	nArgs := map[string]int{"list": 0, "create": 1, "rename": 2, "delete": 1, "move": 3, "trash": 2, "restore": 1, "empty-trash": 0, "hold": 1, "release": 1, "schedule": -1, "export": -1, "import": -1}
+	if n, ok := nArgs[cmd]; !ok || (n >= 0 && len(args) != n) {
+		fmt.Println("Invalid arguments, try 'mailbox help'.")
+		os.Exit(1)
+	}
+
+	var err error
+	switch cmd {
+	case "list":
+		err = mailboxListHandle(a)
+	case "create":
+		err = a.CreateFolder(args[0])
+	case "rename":
+		err = a.RenameFolder(args[0], args[1])
+	case "delete":
+		err = a.DeleteFolder(args[0])
+	case "move":
+		err = a.MoveMessage(args[0], args[1], args[2])
+	case "trash":
+		err = a.DeleteMessage(args[0], args[1])
+	case "restore":
+		var folder string
+		if folder, err = a.RestoreMessage(args[0]); err == nil {
+			fmt.Printf("Message restored to %s.\n", folder)
+		}
+	case "empty-trash":
+		var n int
+		if n, err = a.EmptyTrash(); err == nil {
+			fmt.Printf("%d message(s) deleted.\n", n)
+		}
+	case "hold", "release":
+		_, err = a.UpdateOutboxMeta(args[0], func(meta *app.OutboxMeta) { meta.Hold = cmd == "hold" })
+	case "schedule":
+		err = mailboxScheduleHandle(a, args)
+	case "export":
+		err = mailboxExportHandle(a, args)
+	case "import":
+		err = mailboxImportHandle(a, args)
+	}
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+func mailboxListHandle(a *app.App) error {
+	folders, err := a.Folders()
+	if err != nil {
+		return err
+	}
+	fmtStr := "%-20s %8v %8v\n"
+	fmt.Printf(fmtStr, "folder", "messages", "unread")
+	for _, f := range folders {
+		fmt.Printf(fmtStr, f.Name, f.Messages, f.Unread)
+	}
+	return nil
+}
+
+func mailboxScheduleHandle(a *app.App, args []string) error {
+	var priority int
+	var sendAfter string
+	set := pflag.NewFlagSet("mailbox schedule", pflag.ExitOnError)
+	set.IntVar(&priority, "priority", 0, "")
+	set.StringVar(&sendAfter, "send-after", "", "")
+	set.Parse(args)
+	if set.NArg() != 1 {
+		return fmt.Errorf("missing message id")
+	}
+	var t time.Time
+	if set.Changed("send-after") && sendAfter != "" {
+		var err error
+		if t, err = app.ParseSendAfter(sendAfter, time.Now()); err != nil {
+			return err
+		}
+	}
+	meta, err := a.UpdateOutboxMeta(set.Arg(0), func(meta *app.OutboxMeta) {
+		if set.Changed("priority") {
+			meta.Priority = priority
+		}
+		if set.Changed("send-after") {
+			meta.SendAfter = t
+		}
+	})
+	if err == nil {
+		fmt.Printf("%s: %s.\n", set.Arg(0), meta.Status(time.Now()))
+	}
+	return err
+}
+
+func mailboxExportHandle(a *app.App, args []string) error {
+	var format string
+	var folders []string
+	set := pflag.NewFlagSet("mailbox export", pflag.ExitOnError)
+	set.StringVar(&format, "format", app.FormatEML, "")
+	set.StringArrayVar(&folders, "folder", nil, "")
+	set.Parse(args)
+	if set.NArg() != 1 {
+		return fmt.Errorf("missing destination directory")
+	}
+	n, err := a.ExportMailbox(format, set.Arg(0), folders...)
+	if err != nil && n == 0 {
+		return err
+	}
+	fmt.Printf("%d message(s) exported.\n", n)
+	return err
+}
+
+func mailboxImportHandle(a *app.App, args []string) error {
+	var format, folder string
+	set := pflag.NewFlagSet("mailbox import", pflag.ExitOnError)
+	set.StringVar(&format, "format", "", "")
+	set.StringVar(&folder, "folder", "", "")
+	set.Parse(args)
+	if set.NArg() != 1 {
+		return fmt.Errorf("missing source path")
+	}
+	imported, skipped, err := a.ImportMailbox(format, set.Arg(0), folder)
+	if err != nil && imported+skipped == 0 {
+		return err
+	}
+	fmt.Printf("%d message(s) imported, %d already present.\n", imported, skipped)
+	return err
+}
@@ -16,35 +16,49 @@ import (
 	"strings"
 
 	"github.com/bndr/gotabulate"
+	"github.com/spf13/pflag"
 
 	"github.com/la5nta/pat/app"
 	"github.com/la5nta/wl2k-go/fbb"
 	"github.com/la5nta/wl2k-go/mailbox"
 )
 
-var mailboxes = []string{"in", "out", "sent", "archive"}
-
-func ReadHandle(ctx context.Context, app *app.App, _ []string) {
+func ReadHandle(ctx context.Context, app *app.App, args []string) {
 	cancel := exitOnContextCancellation(ctx)
 	defer cancel()
 
+	var threads bool
+	set := pflag.NewFlagSet("read", pflag.ExitOnError)
+	set.BoolVarP(&threads, "threads", "t", false, "")
+	set.Parse(args)
+
 	w := os.Stdout
+	if threads {
+		readThreads(w, app)
+		return
+	}
 
 	for {
+		folders, err := app.Folders()
+		if err != nil {
+			log.Fatal(err)
+		}
+
 		// Query user for mailbox to list
-		printMailboxes(w)
+		printMailboxes(w, folders)
 		fmt.Fprintf(w, "\nChoose mailbox [n]: ")
 		mailboxIdx, ok := readInt()
 		if !ok {
 			break
-		} else if mailboxIdx+1 > len(mailboxes) {
+		} else if mailboxIdx < 0 || mailboxIdx+1 > len(folders) {
 			fmt.Fprintln(w, "Invalid mailbox number")
 			continue
 		}
 
 		for {
 			// Fetch messages
-			msgs, err := mailbox.LoadMessageDir(filepath.Join(app.Mailbox().MBoxPath, mailboxes[mailboxIdx]))
+			mbox := folders[mailboxIdx].Name
+			msgs, err := app.FolderMessages(mbox)
 			if err != nil {
 				log.Fatal(err)
 			} else if len(msgs) == 0 {
@@ -78,7 +92,7 @@ func ReadHandle(ctx context.Context, app *app.App, _ []string) {
 
 		L:
 			for {
-				fmt.Fprintf(w, "Action [C,r,ra,f,e,d,q,?]: ")
+				fmt.Fprintf(w, "Action [C,r,ra,f,e,m,d,q,?]: ")
 				switch ans := readLine(); ans {
 				case "C", "c", "":
 					break L
@@ -86,15 +100,23 @@ func ReadHandle(ctx context.Context, app *app.App, _ []string) {
 					fmt.Fprint(w, "Delete message? [y/N]: ")
 					if ans := readLine(); strings.EqualFold(ans, "y") {
 						msg := msgs[msgIdx]
-						mbox := mailboxes[mailboxIdx]
-						path := filepath.Join(app.Mailbox().MBoxPath, mbox, msg.MID()+mailbox.Ext)
-						if err := os.Remove(path); err != nil {
+						if err := app.DeleteMessage(msg.MID(), mbox); err != nil {
 							log.Printf("Failed to delete message %s from %s: %v", msg.MID(), mbox, err)
 						} else {
 							fmt.Fprintln(w, "Message deleted.")
 						}
 						break L
 					}
+				case "m":
+					fmt.Fprint(w, "Move to folder: ")
+					if target := readLine(); target != "" {
+						if err := app.MoveMessage(msgs[msgIdx].MID(), mbox, target); err != nil {
+							log.Printf("Failed to move message %s to %s: %v", msgs[msgIdx].MID(), target, err)
+						} else {
+							fmt.Fprintln(w, "Message moved.")
+						}
+						break L
+					}
 				case "r":
 					composeMessage(app, composerFlags{from: app.Options().MyCall, inReplyTo: msgs[msgIdx].MID()}, true)
 				case "ra":
@@ -113,6 +135,7 @@ func ReadHandle(ctx context.Context, app *app.App, _ []string) {
 					fmt.Fprintln(w, "ra - reply all")
 					fmt.Fprintln(w, "f  - forward")
 					fmt.Fprintln(w, "e  - extract (attachments)")
+					fmt.Fprintln(w, "m  - move to folder")
 					fmt.Fprintln(w, "d  - delete")
 					fmt.Fprintln(w, "q  - quit")
 				}
@@ -121,6 +144,95 @@ func ReadHandle(ctx context.Context, app *app.App, _ []string) {
 	}
 }
 
+// readThreads lets the user browse conversation threads across all folders.
+func readThreads(w io.Writer, a *app.App) {
+	for {
+		threads, err := a.Threads()
+		if err != nil {
+			log.Fatal(err)
+		} else if len(threads) == 0 {
+			fmt.Fprintf(w, "(empty)\n")
+			return
+		}
+		printThreads(w, threads)
+
+		fmt.Fprintf(w, "Choose thread [n]: ")
+		threadIdx, ok := readInt()
+		if !ok {
+			return
+		} else if threadIdx < 0 || threadIdx+1 > len(threads) {
+			fmt.Fprintln(w, "Invalid thread number")
+			continue
+		}
+
+		var last *fbb.Message
+		var unread []*fbb.Message
+		for _, m := range threads[threadIdx].Messages {
+			msg, err := mailbox.OpenMessage(filepath.Join(a.Mailbox().MBoxPath, m.Folder, m.MID+mailbox.Ext))
+			if err != nil {
+				log.Printf("Unable to open message %s: %v", m.MID, err)
+				continue
+			}
+			fmt.Fprintf(w, "[%s]\n", m.Folder)
+			printMsg(w, msg)
+			if mailbox.IsUnread(msg) {
+				unread = append(unread, msg)
+			}
+			last = msg
+		}
+		if last == nil {
+			continue
+		}
+
+		if len(unread) > 0 {
+			fmt.Fprintf(w, "Mark thread as read? [Y/n]: ")
+			if ans := readLine(); ans == "" || strings.EqualFold(ans, "y") {
+				for _, msg := range unread {
+					mailbox.SetUnread(msg, false)
+				}
+			}
+		}
+
+		fmt.Fprintf(w, "Action [C,r,ra,q]: ")
+		switch readLine() {
+		case "r":
+			composeMessage(a, composerFlags{from: a.Options().MyCall, inReplyTo: last.Header.Get("X-FilePath")}, true)
+		case "ra":
+			composeMessage(a, composerFlags{from: a.Options().MyCall, inReplyTo: last.Header.Get("X-FilePath"), replyAll: true}, true)
+		case "q":
+			return
+		}
+	}
+}
+
+func printThreads(w io.Writer, threads []app.Thread) {
+	rows := make([][]string, len(threads))
+	for i, t := range threads {
+		var flags string
+		if t.Unread > 0 {
+			flags += "N" // New
+		}
+		participants := strings.Join(t.Participants, ", ")
+		if len(t.Participants) > 3 {
+			participants = strings.Join(t.Participants[:3], ", ") + ", ..."
+		}
+		rows[i] = []string{
+			fmt.Sprintf("%2d", i),
+			flags,
+			t.Subject,
+			strconv.Itoa(t.Count),
+			participants,
+			t.LastDate.String(),
+		}
+	}
+	t := gotabulate.Create(rows)
+	t.SetHeaders([]string{"i", "Flags", "Subject", "Messages", "Participants", "Last"})
+	t.SetAlign("left")
+	t.SetWrapStrings(true)
+	t.SetMaxCellSize(60)
+	fmt.Fprintln(w, t.Render("simple"))
+}
+
 func readInt() (int, bool) {
 	str := readLine()
 	if str == "" {
@@ -136,9 +248,9 @@ func printMsg(w io.Writer, msg *fbb.Message) {
 	fmt.Fprintf(w, "========================================\n\n")
 }
 
-func printMailboxes(w io.Writer) {
-	for i, mbox := range mailboxes {
-		fmt.Fprintf(w, "%d:%s\t", i, mbox)
+func printMailboxes(w io.Writer, folders []app.Folder) {
+	for i, f := range folders {
+		fmt.Fprintf(w, "%d:%s\t", i, f.Name)
 	}
 }
 
@@ -22,6 +22,7 @@ func RMSListHandle(ctx context.Context, a *app.App, args []string) {
 	forceDownload := set.BoolP("force-download", "d", false, "")
 	byDistance := set.BoolP("sort-distance", "s", false, "")
 	byLinkQuality := set.BoolP("sort-link-quality", "q", false, "Sort by predicted link quality")
+	byHistory := set.BoolP("sort-history", "", false, "Sort by connection history")
 	set.Parse(args)
 
 	var query string
@@ -50,12 +51,14 @@ func RMSListHandle(ctx context.Context, a *app.App, args []string) {
 		sort.Sort(app.ByDist(rList))
 	case *byLinkQuality:
 		sort.Sort(sort.Reverse(app.ByLinkQuality(rList)))
+	case *byHistory:
+		sort.Sort(sort.Reverse(app.ByHistory(rList)))
 	}
 
-	fmtStr := "%-9.9s [%-6.6s] %-6.6s %3.3s %-15.15s %14.14s %14.14s %5.5s %s\n"
+	fmtStr := "%-9.9s [%-6.6s] %-6.6s %3.3s %-15.15s %14.14s %14.14s %5.5s %8.8s %s\n"
 
 	// Print header
-	fmt.Printf(fmtStr, "callsign", "gridsq", "dist", "Az", "mode(s)", "dial freq", "center freq", "qual", "url")
+	fmt.Printf(fmtStr, "callsign", "gridsq", "dist", "Az", "mode(s)", "dial freq", "center freq", "qual", "history", "url")
 
 	// Print gateways (separated by blank line)
 	for i, r := range rList {
@@ -71,12 +74,16 @@ func RMSListHandle(ctx context.Context, a *app.App, args []string) {
 }
 
 func printRMS(r app.RMS, qual string) {
-	fmtStr := "%-9.9s [%-6.6s] %-6.6s %3.3s %-15.15s %14.14s %14.14s %5.5s %s\n"
+	fmtStr := "%-9.9s [%-6.6s] %-6.6s %3.3s %-15.15s %14.14s %14.14s %5.5s %8.8s %s\n"
 	distance := strconv.FormatFloat(float64(r.Distance), 'f', 0, 64)
 	azimuth := strconv.FormatFloat(float64(r.Azimuth), 'f', 0, 64)
 	url := ""
 	if r.URL != nil {
 		url = r.URL.String()
 	}
-	fmt.Printf(fmtStr, r.Callsign, r.Gridsquare, distance, azimuth, r.Modes, r.Dial, r.Freq, qual, url)
+	history := "N/A"
+	if h := r.History; h != nil {
+		history = fmt.Sprintf("%d/%d", h.Successes, h.Attempts)
+	}
+	fmt.Printf(fmtStr, r.Callsign, r.Gridsquare, distance, azimuth, r.Modes, r.Dial, r.Freq, qual, history, url)
 }
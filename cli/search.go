@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bndr/gotabulate"
+	"github.com/la5nta/pat/app"
+	"github.com/spf13/pflag"
+)
+
+const (
+	SearchUsage = `[options] query
+
+  Search all mailbox folders (except the trash) for messages matching every term of the query.
+  Terms are matched case-insensitively against subject, from/to/cc, body text, attachment names
+  and form field values. Use double quotes to search for a phrase.
+`
+	SearchExample = `
+  search '"shelter 4"'                   Messages mentioning "shelter 4".
+  search --folder in --folder eoc ICS213 Messages mentioning ICS213 in the inbox or the eoc folder.
+  search -f json water                   Messages mentioning water, as JSON.
+`
+)
+
+func SearchHandle(ctx context.Context, a *app.App, args []string) {
+	var folders []string
+	var format string
+	set := pflag.NewFlagSet("search", pflag.ExitOnError)
+	set.StringArrayVar(&folders, "folder", nil, "")
+	set.StringVarP(&format, "format", "f", "table", "")
+	set.Parse(args)
+
+	query := strings.Join(set.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		fmt.Println("Missing search query, try 'search help'.")
+		os.Exit(1)
+	}
+
+	results, err := a.Search(query, folders...)
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(1)
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	case "table":
+		printSearchResults(results)
+	default:
+		err = fmt.Errorf("unsupported format '%s'", format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printSearchResults(results []app.SearchResult) {
+	if len(results) == 0 {
+		fmt.Println("(no matches)")
+		return
+	}
+	rows := make([][]string, len(results))
+	for i, r := range results {
+		rows[i] = []string{
+			r.Folder,
+			r.MID,
+			r.Date.Local().Format(time.DateTime),
+			r.From,
+			r.Subject,
+			strings.Join(r.Matches, ","),
+		}
+	}
+	t := gotabulate.Create(rows)
+	t.SetHeaders([]string{"Folder", "MID", "Date", "From", "Subject", "Matches"})
+	t.SetAlign("left")
+	t.SetWrapStrings(true)
+	t.SetMaxCellSize(60)
+	fmt.Println(t.Render("simple"))
+	fmt.Printf("%d message(s).\n", len(results))
+}
@@ -0,0 +1,73 @@
+// Package adif implements a minimal writer for the Amateur Data Interchange
+// Format (ADIF), used to export Winlink sessions to logbook software.
+package adif
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const Version = "3.1.4"
+
+// Field is a single ADIF data specifier.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Record is an ordered list of fields making up one QSO.
+type Record []Field
+
+// Set appends the named field, unless value is empty.
+func (r *Record) Set(name, value string) {
+	if value == "" {
+		return
+	}
+	*r = append(*r, Field{strings.ToUpper(name), value})
+}
+
+// Get returns the value of the named field, or an empty string if not set.
+func (r Record) Get(name string) string {
+	for _, f := range r {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// SetTime sets the date and time fields (e.g. QSO_DATE and TIME_ON) for the given time (in UTC).
+func (r *Record) SetTime(dateField, timeField string, t time.Time) {
+	t = t.UTC()
+	r.Set(dateField, t.Format("20060102"))
+	r.Set(timeField, t.Format("150405"))
+}
+
+// WriteHeader writes an ADIF header identifying the exporting program.
+func WriteHeader(w io.Writer, programID, programVersion string) error {
+	_, err := fmt.Fprintf(w, "ADIF export from %s\n%s%s%s<EOH>\n\n",
+		programID,
+		specifier("ADIF_VER", Version),
+		specifier("PROGRAMID", programID),
+		specifier("PROGRAMVERSION", programVersion),
+	)
+	return err
+}
+
+// WriteRecord writes the record terminated by <EOR>.
+func WriteRecord(w io.Writer, r Record) error {
+	var sb strings.Builder
+	for _, f := range r {
+		sb.WriteString(specifier(f.Name, f.Value))
+	}
+	sb.WriteString("<EOR>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func specifier(name, value string) string {
+	// The length is given in number of characters (ADIF strings are ASCII).
+	return fmt.Sprintf("<%s:%d>%s ", name, len(value), value)
+}
@@ -0,0 +1,38 @@
+package adif
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteRecord(t *testing.T) {
+	var r Record
+	r.Set("call", "LA1B")
+	r.SetTime("QSO_DATE", "TIME_ON", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	r.Set("SUBMODE", "")
+	r.Set("MODE", "DYNAMIC")
+
+	var buf bytes.Buffer
+	if err := WriteRecord(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	const expect = "<CALL:4>LA1B <QSO_DATE:8>20240102 <TIME_ON:6>030405 <MODE:7>DYNAMIC <EOR>\n"
+	if got := buf.String(); got != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+	if got := r.Get("mode"); got != "DYNAMIC" {
+		t.Errorf("Get(mode) = %q", got)
+	}
+}
+
+func TestWriteHeader(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHeader(&buf, "Pat", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	const expect = "ADIF export from Pat\n<ADIF_VER:5>3.1.4 <PROGRAMID:3>Pat <PROGRAMVERSION:5>1.0.0 <EOH>\n\n"
+	if got := buf.String(); got != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+}
@@ -0,0 +1,413 @@
+// Package email converts Winlink messages to and from RFC 5322 internet
+// messages, and reads and writes the mailbox formats used by regular email
+// software (single .eml files, mbox and Maildir).
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+// Headers carrying Winlink specific metadata in internet messages.
+const (
+	HeaderMID     = "X-Winlink-MID"
+	HeaderP2POnly = "X-P2POnly"
+	HeaderUnread  = "X-Unread"
+)
+
+// Domain is the mail domain of Winlink (callsign) addresses.
+const Domain = "winlink.org"
+
+// Write writes the message as a MIME encoded internet message.
+//
+// Callsign addresses are written as CALL@winlink.org and attachments as MIME parts. The MID is
+// used for the Message-ID and repeated in the X-Winlink-MID header, so that it is preserved by Read.
+func Write(w io.Writer, msg *fbb.Message) error { return write(w, msg, false) }
+
+// WriteExpress writes the message as a MIME encoded message file as stored by Winlink Express (MID.mime).
+//
+// It differs from Write in that the Message-ID is the bare MID and the date is written in the Winlink date layout.
+func WriteExpress(w io.Writer, msg *fbb.Message) error { return write(w, msg, true) }
+
+func write(w io.Writer, msg *fbb.Message, express bool) error {
+	body, err := msg.Body()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("MIME-Version", "1.0")
+	if express {
+		header("Message-ID", msg.MID())
+		header("Date", msg.Date().UTC().Format(fbb.DateLayout))
+	} else {
+		header("Message-ID", messageID(msg.MID()))
+		header("Date", msg.Date().Format(time.RFC1123Z))
+	}
+	header(HeaderMID, msg.MID())
+	header("From", formatAddresses(msg.From()))
+	if to := msg.To(); len(to) > 0 {
+		header("To", formatAddresses(to...))
+	}
+	if cc := msg.Cc(); len(cc) > 0 {
+		header("Cc", formatAddresses(cc...))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject()))
+	if v := strings.Trim(msg.Header.Get("In-Reply-To"), "<> "); v != "" {
+		header("In-Reply-To", messageID(v))
+	}
+	if refs := strings.Fields(msg.Header.Get("References")); len(refs) > 0 {
+		for i, ref := range refs {
+			refs[i] = messageID(strings.Trim(ref, "<>"))
+		}
+		header("References", strings.Join(refs, " "))
+	}
+	for _, k := range []string{HeaderP2POnly, HeaderUnread} {
+		if v := msg.Header.Get(k); v != "" {
+			header(k, v)
+		}
+	}
+
+	if len(msg.Files()) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return err
+		}
+		_, err := buf.WriteTo(w)
+		return err
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+	buf.WriteString("\r\n")
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeQuotedPrintable(part, body); err != nil {
+		return err
+	}
+	for _, f := range msg.Files() {
+		contentType := mime.TypeByExtension(filepath.Ext(f.Name()))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": f.Name()})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeBase64(part, f.Data()); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// Read parses an internet message and converts it to a Winlink message.
+//
+// The MID is taken from the X-Winlink-MID header or a Message-ID written by Write or WriteExpress. Messages
+// originating elsewhere are assigned a new MID. The first text/plain part is used as body,
+// and all other parts are converted to attachments. The message is marked as unread according
+// to the X-Unread header, or the mbox Status header if present.
+func Read(r io.Reader) (*fbb.Message, error) { return ReadWith(r, attachFile) }
+
+// AttachFunc adds an attachment to a message.
+type AttachFunc func(msg *fbb.Message, filename, contentType string, r io.Reader) error
+
+// ReadWith is like Read, but adds attachments using the given function (e.g. to convert images).
+func ReadWith(r io.Reader, attach AttachFunc) (*fbb.Message, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	from := parseAddresses(m.Header.Get("From"))
+	if len(from) == 0 {
+		return nil, fmt.Errorf("missing From header")
+	}
+	msg := fbb.NewMessage(fbb.Private, from[0])
+	if mid := parseMID(m.Header); mid != "" {
+		msg.Header.Set(fbb.HEADER_MID, mid)
+	}
+	if date, err := m.Header.Date(); err == nil {
+		msg.SetDate(date)
+	} else if date, err := fbb.ParseDate(m.Header.Get("Date")); err == nil && !date.IsZero() {
+		msg.SetDate(date) // Winlink date layout, as written by Winlink Express.
+	}
+	msg.AddTo(parseAddresses(m.Header.Get("To"))...)
+	msg.AddCc(parseAddresses(m.Header.Get("Cc"))...)
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		subject = m.Header.Get("Subject")
+	}
+	msg.SetSubject(subject)
+	if v := localPart(m.Header.Get("In-Reply-To")); v != "" {
+		msg.Header.Set("In-Reply-To", v)
+	}
+	var refs []string
+	for _, ref := range strings.Fields(m.Header.Get("References")) {
+		if ref = localPart(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) > 0 {
+		msg.Header.Set("References", strings.Join(refs, " "))
+	}
+	if strings.EqualFold(m.Header.Get(HeaderP2POnly), "true") {
+		msg.Header.Set(HeaderP2POnly, "true")
+	}
+	unread := strings.EqualFold(m.Header.Get(HeaderUnread), "true")
+	if status := m.Header.Get("Status"); status != "" {
+		unread = !strings.Contains(status, "R") // mbox read flag, as updated by mail clients.
+	}
+	if unread {
+		msg.Header.Set(HeaderUnread, "true")
+	}
+
+	var body string
+	var haveBody bool
+	var html []byte
+	err = walkParts(textproto.MIMEHeader(m.Header), m.Body, func(h textproto.MIMEHeader, data []byte) error {
+		mediaType, params, _ := mime.ParseMediaType(h.Get("Content-Type"))
+		filename := partFilename(h)
+		switch {
+		case filename == "" && !haveBody && (mediaType == "" || mediaType == "text/plain"):
+			body, haveBody = decodeCharset(params["charset"], data), true
+		case filename == "" && mediaType == "text/html" && html == nil:
+			html = data // Most likely an alternative representation of the text body.
+		default:
+			if filename == "" {
+				filename = fmt.Sprintf("part%d%s", len(msg.Files())+1, extension(mediaType))
+			}
+			return attach(msg, filename, h.Get("Content-Type"), bytes.NewReader(data))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !haveBody && html != nil {
+		if err := attach(msg, "message.html", "text/html", bytes.NewReader(html)); err != nil {
+			return nil, err
+		}
+	}
+	if err := msg.SetBody(body); err != nil {
+		// The body contains characters not representable in the Winlink charset.
+		if err := msg.SetBody(strings.Map(latin1Only, body)); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+func attachFile(msg *fbb.Message, filename, _ string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	msg.AddFile(fbb.NewFile(filename, data))
+	return nil
+}
+
+// walkParts calls fn with the header and decoded content of each leaf part of the entity.
+func walkParts(h textproto.MIMEHeader, r io.Reader, fn func(textproto.MIMEHeader, []byte) error) error {
+	mediaType, params, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := walkParts(p.Header, p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	data, err := io.ReadAll(decodeTransfer(h.Get("Content-Transfer-Encoding"), r))
+	if err != nil {
+		return fmt.Errorf("failed to decode %s part: %w", mediaType, err)
+	}
+	return fn(h, data)
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r) // Line breaks are ignored by the decoder.
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// decodeCharset converts text in the given charset to UTF-8. Only UTF-8 (and ASCII) and the
+// ISO-8859-1 family are supported, text in other charsets is returned unmodified.
+func decodeCharset(charset string, data []byte) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso-8859-15", "windows-1252", "latin1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	default:
+		return string(data)
+	}
+}
+
+func latin1Only(r rune) rune {
+	if r > 0xFF {
+		return '?'
+	}
+	return r
+}
+
+func partFilename(h textproto.MIMEHeader) string {
+	if _, params, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return filepath.Base(params["filename"])
+	}
+	if _, params, err := mime.ParseMediaType(h.Get("Content-Type")); err == nil && params["name"] != "" {
+		return filepath.Base(params["name"])
+	}
+	return ""
+}
+
+func extension(mediaType string) string {
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// parseMID returns the Winlink MID of the message, if known.
+func parseMID(h mail.Header) string {
+	if mid := strings.TrimSpace(h.Get(HeaderMID)); validMID(mid) {
+		return mid
+	}
+	id := strings.Trim(strings.TrimSpace(h.Get("Message-ID")), "<>")
+	if local, domain, ok := strings.Cut(id, "@"); ok && strings.EqualFold(domain, Domain) && validMID(local) {
+		return local
+	} else if !ok && validMID(id) {
+		return id // Bare MID, as written by Winlink Express.
+	}
+	return ""
+}
+
+// validMID reports whether str is a valid Winlink MID (up to 12 alphanumeric characters).
+func validMID(str string) bool {
+	if len(str) == 0 || len(str) > 12 {
+		return false
+	}
+	for _, r := range str {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// localPart returns the MID of a message ID written by Write (<MID@winlink.org>), or the message ID itself.
+func localPart(id string) string {
+	id = strings.Trim(strings.TrimSpace(id), "<>")
+	if local, domain, ok := strings.Cut(id, "@"); ok && strings.EqualFold(domain, Domain) {
+		return local
+	}
+	return id
+}
+
+func messageID(mid string) string {
+	if strings.Contains(mid, "@") {
+		return "<" + mid + ">"
+	}
+	return "<" + mid + "@" + Domain + ">"
+}
+
+// formatAddresses formats the given addresses as an RFC 5322 address list.
+func formatAddresses(addrs ...fbb.Address) string {
+	list := make([]string, len(addrs))
+	for i, addr := range addrs {
+		switch {
+		case addr.Proto == "":
+			list[i] = addr.Addr + "@" + Domain
+		case strings.EqualFold(addr.Proto, "SMTP"):
+			list[i] = addr.Addr
+		default:
+			list[i] = addr.String()
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// parseAddresses returns the addresses of an RFC 5322 address list in Winlink notation.
+func parseAddresses(list string) []string {
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	var addrs []string
+	parsed, err := new(mail.AddressParser).ParseList(list)
+	if err != nil {
+		// Be lenient with malformed lists (e.g. bare callsigns).
+		for _, s := range strings.Split(list, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				addrs = append(addrs, fbb.AddressFromString(s).String())
+			}
+		}
+		return addrs
+	}
+	for _, addr := range parsed {
+		addrs = append(addrs, fbb.AddressFromString(addr.Address).String())
+	}
+	return addrs
+}
+
+func writeQuotedPrintable(w io.Writer, text string) error {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	qw := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qw, text); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+func writeBase64(w io.Writer, data []byte) error {
+	const lineLen = 76
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(lineLen, len(encoded))
+		if _, err := io.WriteString(w, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
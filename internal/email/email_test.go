@@ -0,0 +1,199 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+func testMessage(subject string) *fbb.Message {
+	msg := fbb.NewMessage(fbb.Private, "LA5NTA")
+	msg.AddTo("N0CALL", "ops@example.com")
+	msg.AddCc("LA1B")
+	msg.SetSubject(subject)
+	msg.SetBody("Line one\r\nFrom the field: æøå\r\n")
+	msg.SetDate(time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC))
+	msg.Header.Set(HeaderP2POnly, "true")
+	msg.Header.Set("In-Reply-To", "ORIGINAL0001")
+	msg.AddFile(fbb.NewFile("report.pdf", []byte{0x25, 0x50, 0x44, 0x46, 0x00, 0xff}))
+	return msg
+}
+
+func TestRoundTrip(t *testing.T) {
+	msg := testMessage("Incident report ÆØÅ")
+	var buf bytes.Buffer
+	if err := Write(&buf, msg); err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.String()
+	for _, expect := range []string{"Message-ID: <" + msg.MID() + "@winlink.org>", "From: LA5NTA@winlink.org", "To: N0CALL@winlink.org, ops@example.com", "multipart/mixed"} {
+		if !strings.Contains(raw, expect) {
+			t.Errorf("expected %q in output:\n%s", expect, raw)
+		}
+	}
+
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := got.Body()
+	switch {
+	case got.MID() != msg.MID():
+		t.Errorf("MID: expected %s, got %s", msg.MID(), got.MID())
+	case !got.Date().Equal(msg.Date()):
+		t.Errorf("Date: expected %s, got %s", msg.Date(), got.Date())
+	case got.Subject() != msg.Subject():
+		t.Errorf("Subject: expected %q, got %q", msg.Subject(), got.Subject())
+	case got.From() != msg.From() || len(got.To()) != 2 || got.To()[1] != msg.To()[1] || got.Cc()[0].Addr != "LA1B":
+		t.Errorf("unexpected addresses: %v %v %v", got.From(), got.To(), got.Cc())
+	case body != "Line one\r\nFrom the field: æøå\r\n":
+		t.Errorf("unexpected body: %q", body)
+	case len(got.Files()) != 1 || got.Files()[0].Name() != "report.pdf" || !bytes.Equal(got.Files()[0].Data(), msg.Files()[0].Data()):
+		t.Errorf("unexpected attachments: %v", got.Files())
+	case got.Header.Get(HeaderP2POnly) != "true" || got.Header.Get("In-Reply-To") != "ORIGINAL0001":
+		t.Errorf("unexpected headers: %v", got.Header)
+	}
+}
+
+func TestExpress(t *testing.T) {
+	msg := testMessage("Express")
+	var buf bytes.Buffer
+	if err := WriteExpress(&buf, msg); err != nil {
+		t.Fatal(err)
+	}
+	raw := strings.Replace(buf.String(), HeaderMID+": "+msg.MID()+"\r\n", "", 1)
+	if !strings.Contains(raw, "Message-ID: "+msg.MID()+"\r\n") || !strings.Contains(raw, "Date: 2026/10/01 12:30\r\n") {
+		t.Errorf("unexpected output:\n%s", raw)
+	}
+	got, err := Read(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MID() != msg.MID() || !got.Date().Equal(msg.Date()) {
+		t.Errorf("expected MID %s and date %s, got %s and %s", msg.MID(), msg.Date(), got.MID(), got.Date())
+	}
+}
+
+func TestReadForeign(t *testing.T) {
+	raw := "From: Agency <duty@agency.example>\r\n" +
+		"To: LA5NTA@winlink.org\r\n" +
+		"Subject: =?utf-8?q?Tasking_=C3=B8?=\r\n" +
+		"Message-ID: <1234.abcd@agency.example>\r\n" +
+		"Date: Thu, 01 Oct 2026 14:00:00 +0200\r\n" +
+		"Status: RO\r\n" +
+		"Content-Type: multipart/alternative; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\nContent-Type: text/plain; charset=iso-8859-1\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nBl=E5 sone\r\n" +
+		"--b\r\nContent-Type: text/html\r\n\r\n<p>Bl&aring; sone</p>\r\n" +
+		"--b--\r\n"
+	msg, err := Read(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := msg.Body()
+	if body != "Blå sone\r\n" || len(msg.Files()) != 0 {
+		t.Errorf("unexpected content: %q %v", body, msg.Files())
+	}
+	if msg.From().String() != "SMTP:duty@agency.example" || msg.To()[0].Addr != "LA5NTA" || msg.Subject() != "Tasking ø" {
+		t.Errorf("unexpected headers: %v", msg.Header)
+	}
+	if len(msg.MID()) != 12 || msg.Header.Get(HeaderUnread) != "" {
+		t.Errorf("expected new MID and read state, got %v", msg.Header)
+	}
+}
+
+func TestMbox(t *testing.T) {
+	a, b := testMessage("First"), testMessage("Second")
+	b.SetBody("From here on\r\n>From there\r\n")
+	b.Header.Set(HeaderUnread, "true")
+	var buf bytes.Buffer
+	for _, msg := range []*fbb.Message{a, b} {
+		if err := WriteMbox(&buf, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(buf.String(), "\nFrom "); n != 1 {
+		t.Errorf("expected 1 unquoted From_ line after the first, got %d", n)
+	}
+	var got []*fbb.Message
+	if err := ReadMbox(&buf, func(msg *fbb.Message) error { got = append(got, msg); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].MID() != a.MID() || got[1].MID() != b.MID() {
+		t.Fatalf("unexpected messages: %v", got)
+	}
+	if body, _ := got[1].Body(); body != "From here on\r\n>From there\r\n" || got[1].Header.Get(HeaderUnread) != "true" || got[0].Header.Get(HeaderUnread) != "" {
+		t.Errorf("unexpected second message: %q %v", body, got[1].Header)
+	}
+	if err := ReadMbox(strings.NewReader("Subject: hello\n"), nil); err == nil {
+		t.Error("expected error reading invalid mbox")
+	}
+}
+
+func TestMaildir(t *testing.T) {
+	dir := t.TempDir()
+	read, unread := testMessage("Read"), testMessage("Unread")
+	unread.Header.Set(HeaderUnread, "true")
+	for _, msg := range []*fbb.Message{read, unread} {
+		if err := WriteMaildir(dir, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !IsMaildir(dir) {
+		t.Fatal("expected Maildir to be created")
+	}
+	got := map[string]bool{}
+	err := ReadMaildir(dir, func(msg *fbb.Message) error {
+		got[msg.MID()] = msg.Header.Get(HeaderUnread) == "true"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unread, ok := got[unread.MID()]; len(got) != 2 || !ok || !unread || got[read.MID()] {
+		t.Errorf("unexpected read state: %v", got)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(dir, "cur"))
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), maildirInfoSeparator()+"2,S") {
+		t.Errorf("unexpected delivered file names: %v", entries)
+	}
+
+	// Any of the info separators is accepted.
+	dir = t.TempDir()
+	for _, sub := range []string{"cur", "new"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := map[string]bool{}
+	for i, suffix := range []string{":2,S", "!2,RS", ";2,S", "!2,"} {
+		msg := testMessage(suffix)
+		var buf bytes.Buffer
+		if err := Write(&buf, msg); err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("%d.%s%s", i, msg.MID(), suffix)
+		if err := os.WriteFile(filepath.Join(dir, "cur", name), buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		expect[msg.MID()] = suffix == "!2,"
+	}
+	got = map[string]bool{}
+	err = ReadMaildir(dir, func(msg *fbb.Message) error {
+		got[msg.MID()] = msg.Header.Get(HeaderUnread) == "true"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expect) {
+		t.Errorf("expected read state %v, got %v", expect, got)
+	}
+}
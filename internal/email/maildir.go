@@ -0,0 +1,122 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+// maildirInfoSeparators separate the unique name and the info (flags) of Maildir file names.
+//
+// The standard separator (':') is not allowed in Windows file names, where '!' is commonly used
+// instead. Some clients use ';'.
+var maildirInfoSeparators = []string{":", "!", ";"}
+
+// maildirInfoSeparator returns the info separator used for delivered messages on this platform.
+func maildirInfoSeparator() string {
+	if runtime.GOOS == "windows" {
+		return "!"
+	}
+	return ":"
+}
+
+// IsMaildir reports whether dir is a Maildir (a directory with cur and new subdirectories).
+func IsMaildir(dir string) bool {
+	for _, sub := range []string{"cur", "new"} {
+		if fi, err := os.Stat(filepath.Join(dir, sub)); err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteMaildir delivers the message to the Maildir at dir, creating the directory structure if needed.
+//
+// Unread messages are delivered to new, other messages to cur with the Seen flag set.
+func WriteMaildir(dir string, msg *fbb.Message) error {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, msg); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d.%s.pat", msg.Date().Unix(), msg.MID())
+	tmp := filepath.Join(dir, "tmp", name)
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	dst := filepath.Join(dir, "cur", name+maildirInfoSeparator()+"2,S")
+	if msg.Header.Get(HeaderUnread) == "true" {
+		dst = filepath.Join(dir, "new", name)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// ReadMaildir calls fn for each message in the Maildir at dir.
+//
+// Messages in new, and messages in cur without the Seen flag, are marked as unread.
+func ReadMaildir(dir string, fn func(*fbb.Message) error) error {
+	if !IsMaildir(dir) {
+		return fmt.Errorf("%s is not a Maildir", dir)
+	}
+	for _, sub := range []string{"cur", "new"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			msg, err := readFile(filepath.Join(dir, sub, e.Name()))
+			if err != nil {
+				return err
+			}
+			if sub == "new" || !strings.Contains(maildirFlags(e.Name()), "S") {
+				msg.Header.Set(HeaderUnread, "true")
+			} else {
+				msg.Header.Del(HeaderUnread)
+			}
+			if err := fn(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// maildirFlags returns the flags of the given Maildir file name (accepting any of the info separators).
+func maildirFlags(name string) string {
+	for _, sep := range maildirInfoSeparators {
+		if _, flags, ok := strings.Cut(name, sep+"2,"); ok {
+			return flags
+		}
+	}
+	return ""
+}
+
+func readFile(path string) (*fbb.Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	msg, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,82 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/la5nta/wl2k-go/fbb"
+)
+
+// fromLine matches (quoted) mbox From_ lines in message content.
+var fromLine = regexp.MustCompile(`^>*From `)
+
+// WriteMbox appends the message to an mbox file in the mboxrd format.
+//
+// A Status header is added reflecting the read state of the message.
+func WriteMbox(w io.Writer, msg *fbb.Message) error {
+	var buf bytes.Buffer
+	if err := Write(&buf, msg); err != nil {
+		return err
+	}
+	status := "RO"
+	if msg.Header.Get(HeaderUnread) == "true" {
+		status = "O"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "From %s %s\n", formatAddresses(msg.From()), msg.Date().UTC().Format(time.ANSIC))
+	fmt.Fprintf(bw, "Status: %s\n", status)
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if fromLine.MatchString(line) {
+			bw.WriteByte('>')
+		}
+		bw.WriteString(line + "\n")
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// ReadMbox calls fn for each message in the given mbox (mboxrd or mboxo) file.
+func ReadMbox(r io.Reader, fn func(*fbb.Message) error) error {
+	br := bufio.NewReader(r)
+	var buf bytes.Buffer
+	var started bool
+	flush := func() error {
+		if !started {
+			return nil
+		}
+		msg, err := Read(&buf)
+		if err != nil {
+			return err
+		}
+		buf.Reset()
+		return fn(msg)
+	}
+	for {
+		line, err := br.ReadString('\n')
+		switch {
+		case strings.HasPrefix(line, "From "):
+			if err := flush(); err != nil {
+				return err
+			}
+			started = true
+		case !started && strings.TrimSpace(line) == "":
+		case !started:
+			return fmt.Errorf("not an mbox file")
+		case fromLine.MatchString(line):
+			buf.WriteString(line[1:])
+		default:
+			buf.WriteString(line)
+		}
+		if err == io.EOF {
+			return flush()
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -451,8 +451,8 @@ func (m *Manager) downloadAndUnzipForms(ctx context.Context, downloadLink string
 	return nil
 }
 
-// RenderForm finds the associated form and returns the filled-in form in HTML given the contents of a form attachment
-func (m *Manager) RenderForm(data []byte, inReplyToMsg *fbb.Message, inReplyToPath string) (string, error) {
+// ParseFormXML returns the form parameters and variables given the contents of a form attachment.
+func ParseFormXML(data []byte) (params, vars map[string]string, err error) {
 	type Node struct {
 		XMLName xml.Name
 		Content []byte `xml:",innerxml"`
@@ -465,28 +465,37 @@ func (m *Manager) RenderForm(data []byte, inReplyToMsg *fbb.Message, inReplyToPa
 	}
 
 	var n1 Node
-	formParams := make(map[string]string)
-	formVars := make(map[string]string)
+	params = make(map[string]string)
+	vars = make(map[string]string)
 
 	if err := xml.Unmarshal(data, &n1); err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
 	if n1.XMLName.Local != "RMS_Express_Form" {
-		return "", errors.New("missing RMS_Express_Form tag in form XML")
+		return nil, nil, errors.New("missing RMS_Express_Form tag in form XML")
 	}
 	for _, n2 := range n1.Nodes {
 		switch n2.XMLName.Local {
 		case "form_parameters":
 			for _, n3 := range n2.Nodes {
-				formParams[n3.XMLName.Local] = string(n3.Content)
+				params[n3.XMLName.Local] = string(n3.Content)
 			}
 		case "variables":
 			for _, n3 := range n2.Nodes {
-				formVars[n3.XMLName.Local] = string(n3.Content)
+				vars[n3.XMLName.Local] = string(n3.Content)
 			}
 		}
 	}
+	return params, vars, nil
+}
+
+// RenderForm finds the associated form and returns the filled-in form in HTML given the contents of a form attachment
+func (m *Manager) RenderForm(data []byte, inReplyToMsg *fbb.Message, inReplyToPath string) (string, error) {
+	formParams, formVars, err := ParseFormXML(data)
+	if err != nil {
+		return "", err
+	}
 
 	filesMap := formFilesFromPath(m.config.FormsPath)
 	switch {
@@ -0,0 +1,280 @@
+package imapd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const dateTimeLayout = "02-Jan-2006 15:04:05 -0700"
+
+func (s *session) fetch(uid bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(args) < 3 {
+		return "", bad("Missing fetch items")
+	}
+	items, err := parseFetchItems(args[2])
+	if err != nil {
+		return "", bad("%v", err)
+	}
+	if uid {
+		items = append([]fetchItem{{name: "UID"}}, items...)
+	}
+	idx, err := s.resolve(strs[0], uid)
+	if err != nil {
+		return "", err
+	}
+	for _, i := range idx {
+		resp, err := s.fetchMessage(i, items)
+		if err != nil {
+			continue // The message has been removed by others. The next poll will report it.
+		}
+		s.untagged("%d FETCH (%s)", i+1, strings.Join(resp, " "))
+	}
+	return "", nil
+}
+
+// fetchMessage returns the FETCH response data of the message with the given index.
+func (s *session) fetchMessage(i int, items []fetchItem) ([]string, error) {
+	m := &s.msgs[i]
+	var (
+		raw  []byte
+		root *entity
+	)
+	var setSeen, hasFlags, hasUID bool
+	for _, item := range items {
+		setSeen = setSeen || (item.setsSeen() && !m.Seen && !s.readOnly)
+		if item.needsContent() && raw == nil {
+			var err error
+			if raw, err = s.backend.Fetch(s.selected, m.UID); err != nil {
+				return nil, err
+			}
+			root = parseEntity(raw)
+		}
+	}
+	if setSeen {
+		if err := s.backend.SetSeen(s.selected, m.UID, true); err != nil {
+			return nil, err
+		}
+		m.Seen = true
+	}
+
+	resp := make([]string, 0, len(items)+1)
+	for _, item := range items {
+		switch item.name {
+		case "UID":
+			if hasUID {
+				continue
+			}
+			hasUID = true
+			resp = append(resp, fmt.Sprintf("UID %d", m.UID))
+		case "FLAGS":
+			hasFlags = true
+			resp = append(resp, fmt.Sprintf("FLAGS (%s)", s.flags(*m)))
+		case "INTERNALDATE":
+			resp = append(resp, "INTERNALDATE "+quote(m.Date.Format(dateTimeLayout)))
+		case "RFC822.SIZE":
+			resp = append(resp, fmt.Sprintf("RFC822.SIZE %d", m.Size))
+		case "ENVELOPE":
+			resp = append(resp, "ENVELOPE "+envelope(root.fields))
+		case "BODYSTRUCTURE":
+			resp = append(resp, "BODYSTRUCTURE "+bodyStructure(root, true))
+		case "RFC822":
+			resp = append(resp, "RFC822 "+literal(string(raw)))
+		case "RFC822.HEADER":
+			resp = append(resp, "RFC822.HEADER "+literal(string(root.header)))
+		case "RFC822.TEXT":
+			resp = append(resp, "RFC822.TEXT "+literal(string(root.body)))
+		case "BODY", "BODY.PEEK":
+			if !item.hasSection {
+				resp = append(resp, "BODY "+bodyStructure(root, false))
+				continue
+			}
+			data, err := sectionData(root, raw, item.section)
+			if err != nil {
+				data = nil // Non-existent sections are returned as empty.
+			}
+			name := "BODY[" + item.section + "]"
+			if item.partial {
+				name += fmt.Sprintf("<%d>", item.offset)
+				data = data[min(item.offset, len(data)):]
+				data = data[:min(item.length, len(data))]
+			}
+			resp = append(resp, name+" "+literal(string(data)))
+		}
+	}
+	if setSeen && !hasFlags {
+		resp = append(resp, fmt.Sprintf("FLAGS (%s)", s.flags(*m)))
+	}
+	return resp, nil
+}
+
+// fetchItem is a FETCH data item (e.g. FLAGS or BODY.PEEK[HEADER]<0.1024>).
+type fetchItem struct {
+	name       string // Upper-case item name (e.g. BODY.PEEK).
+	section    string // Section specification, as requested.
+	hasSection bool
+	partial    bool
+	offset     int
+	length     int
+}
+
+var fetchMacros = map[string][]string{
+	"ALL":  {"FLAGS", "INTERNALDATE", "RFC822.SIZE", "ENVELOPE"},
+	"FAST": {"FLAGS", "INTERNALDATE", "RFC822.SIZE"},
+	"FULL": {"FLAGS", "INTERNALDATE", "RFC822.SIZE", "ENVELOPE", "BODY"},
+}
+
+var fetchNames = []string{"UID", "FLAGS", "INTERNALDATE", "RFC822.SIZE", "ENVELOPE", "BODY", "BODYSTRUCTURE", "RFC822", "RFC822.HEADER", "RFC822.TEXT"}
+
+// parseFetchItems parses the data items argument of a FETCH command.
+func parseFetchItems(field any) ([]fetchItem, error) {
+	var names []string
+	switch f := field.(type) {
+	case string:
+		if macro, ok := fetchMacros[strings.ToUpper(f)]; ok {
+			names = macro
+		} else {
+			names = []string{f}
+		}
+	case []any:
+		for _, v := range f {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid fetch item")
+			}
+			names = append(names, str)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("missing fetch items")
+	}
+	items := make([]fetchItem, len(names))
+	for i, name := range names {
+		item, err := parseFetchItem(name)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func parseFetchItem(str string) (fetchItem, error) {
+	i := strings.IndexByte(str, '[')
+	if i < 0 {
+		name := strings.ToUpper(str)
+		for _, n := range fetchNames {
+			if n == name {
+				return fetchItem{name: name}, nil
+			}
+		}
+		return fetchItem{}, fmt.Errorf("unknown fetch item '%s'", str)
+	}
+	item := fetchItem{name: strings.ToUpper(str[:i]), hasSection: true}
+	j := strings.LastIndexByte(str, ']')
+	if (item.name != "BODY" && item.name != "BODY.PEEK") || j < i {
+		return fetchItem{}, fmt.Errorf("invalid fetch item '%s'", str)
+	}
+	item.section = str[i+1 : j]
+	if rest := str[j+1:]; rest != "" {
+		a, b, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(rest, "<"), ">"), ".")
+		offset, err1 := strconv.Atoi(a)
+		length, err2 := strconv.Atoi(b)
+		if !ok || err1 != nil || err2 != nil || offset < 0 || length <= 0 || rest[0] != '<' || rest[len(rest)-1] != '>' {
+			return fetchItem{}, fmt.Errorf("invalid partial specification '%s'", rest)
+		}
+		item.partial, item.offset, item.length = true, offset, length
+	}
+	return item, nil
+}
+
+// setsSeen reports whether fetching the item implicitly sets the \Seen flag.
+func (item fetchItem) setsSeen() bool {
+	switch item.name {
+	case "BODY":
+		return item.hasSection
+	case "RFC822", "RFC822.TEXT":
+		return true
+	default:
+		return false
+	}
+}
+
+// needsContent reports whether the message content is needed to respond to the item.
+func (item fetchItem) needsContent() bool {
+	switch item.name {
+	case "UID", "FLAGS", "INTERNALDATE", "RFC822.SIZE":
+		return false
+	default:
+		return true
+	}
+}
+
+// sectionData returns the content of the given body section of the message.
+func sectionData(root *entity, raw []byte, section string) ([]byte, error) {
+	var path []int
+	rest := section
+	for rest != "" {
+		head, tail, _ := strings.Cut(rest, ".")
+		n, err := strconv.Atoi(head)
+		if err != nil {
+			break
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("invalid section '%s'", section)
+		}
+		path, rest = append(path, n), tail
+	}
+	e := root
+	if len(path) > 0 {
+		var ok bool
+		if e, ok = root.part(path); !ok {
+			return nil, fmt.Errorf("no such section '%s'", section)
+		}
+	}
+
+	keyword, fieldList, _ := strings.Cut(rest, " ")
+	keyword = strings.ToUpper(keyword)
+	if keyword == "" {
+		if len(path) == 0 {
+			return raw, nil
+		}
+		return e.body, nil
+	}
+	if keyword == "MIME" {
+		if len(path) == 0 {
+			return nil, fmt.Errorf("invalid section '%s'", section)
+		}
+		return e.header, nil
+	}
+
+	// HEADER and TEXT refer to the top-level message or an encapsulated message.
+	msg := e
+	if len(path) > 0 {
+		if msg = e.message; msg == nil {
+			return nil, fmt.Errorf("no such section '%s'", section)
+		}
+	}
+	switch keyword {
+	case "HEADER":
+		return msg.header, nil
+	case "TEXT":
+		return msg.body, nil
+	case "HEADER.FIELDS", "HEADER.FIELDS.NOT":
+		fieldList = strings.Trim(strings.TrimSpace(fieldList), "()")
+		names := strings.Fields(fieldList)
+		for i, n := range names {
+			names[i] = strings.Trim(n, `"`)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("missing header field names")
+		}
+		return filterFields(msg.header, names, keyword == "HEADER.FIELDS.NOT"), nil
+	default:
+		return nil, fmt.Errorf("invalid section '%s'", section)
+	}
+}
@@ -0,0 +1,699 @@
+// Package imapd implements an IMAP4rev1 server (RFC 3501) exposing a message
+// store to regular mail clients.
+//
+// Only a subset of the protocol is implemented: plain text LOGIN (no TLS), a
+// flat folder hierarchy, and the \Seen and \Deleted flags. The LITERAL+,
+// MOVE, UNSELECT and SPECIAL-USE extensions are supported.
+package imapd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	capabilities = "IMAP4rev1 LITERAL+ MOVE UNSELECT SPECIAL-USE"
+	idleTimeout  = 30 * time.Minute
+	delimiter    = "/"
+)
+
+// ErrNoSuchFolder is returned by the backend when a folder does not exist.
+var ErrNoSuchFolder = errors.New("no such folder")
+
+// Folder is a folder (IMAP mailbox) of the store.
+type Folder struct {
+	Name       string
+	Attributes []string // Special-use attributes (e.g. \Sent).
+}
+
+// Message describes a message in a folder.
+type Message struct {
+	UID  uint32
+	Seen bool
+	Date time.Time
+	Size int // Size of the message returned by Fetch, in bytes.
+}
+
+// Status is the UID state of a folder.
+type Status struct {
+	UIDValidity uint32
+	UIDNext     uint32
+}
+
+// Backend is the message store served by the server. The inbox is named INBOX.
+//
+// The methods must be safe for concurrent use.
+type Backend interface {
+	Authenticate(username, password string) bool
+	Folders() ([]Folder, error)
+	CreateFolder(name string) error
+	DeleteFolder(name string) error
+	RenameFolder(name, newName string) error
+
+	// Messages returns the UID state and the messages of the folder, ordered by UID.
+	Messages(folder string) (Status, []Message, error)
+	// Fetch returns the message with the given UID as an RFC 5322 message (with CRLF line endings).
+	Fetch(folder string, uid uint32) ([]byte, error)
+	SetSeen(folder string, uid uint32, seen bool) error
+	// Expunge permanently removes the message from the folder.
+	Expunge(folder string, uid uint32) error
+	Copy(folder string, uid uint32, dest string) error
+	Move(folder string, uid uint32, dest string) error
+	Append(folder string, data []byte, seen bool) error
+}
+
+// Server is an IMAP server.
+type Server struct {
+	Addr    string // TCP address to listen on.
+	Backend Backend
+}
+
+// ListenAndServe listens on the server address and serves connections until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	go func() { <-ctx.Done(); ln.Close() }()
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on the listener until it is closed.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// statusError is a NO or BAD command completion.
+type statusError struct{ status, text string }
+
+func (e *statusError) Error() string { return e.text }
+
+func no(format string, args ...any) error  { return &statusError{"NO", fmt.Sprintf(format, args...)} }
+func bad(format string, args ...any) error { return &statusError{"BAD", fmt.Sprintf(format, args...)} }
+
+type session struct {
+	backend       Backend
+	conn          net.Conn
+	w             *bufio.Writer
+	p             *parser
+	authenticated bool
+
+	// State of the selected folder.
+	selected string
+	readOnly bool
+	validity uint32
+	msgs     []Message
+	deleted  map[uint32]bool
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	sess := &session{backend: s.Backend, conn: conn, w: bufio.NewWriter(conn)}
+	sess.p = &parser{r: bufio.NewReader(conn), cont: func() error {
+		sess.w.WriteString("+ Ready for literal data\r\n")
+		return sess.w.Flush()
+	}}
+	sess.untagged("OK [CAPABILITY %s] Pat IMAP server ready", capabilities)
+	sess.w.Flush()
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		tag, fields, err := sess.p.readCommand()
+		var done bool
+		switch {
+		case errors.As(err, new(syntaxError)) && tag != "":
+			fmt.Fprintf(sess.w, "%s BAD %v\r\n", tag, err)
+		case errors.As(err, new(syntaxError)):
+			sess.untagged("BAD %v", err)
+		case err != nil:
+			sess.untagged("BYE %v", err)
+			sess.w.Flush()
+			return
+		default:
+			done = sess.handle(tag, fields)
+		}
+		if err := sess.w.Flush(); err != nil || done {
+			return
+		}
+	}
+}
+
+type handlerFunc func(s *session, uid bool, args []any) (code string, err error)
+
+const (
+	stateAny = iota
+	stateNotAuthenticated
+	stateAuthenticated
+	stateSelected
+)
+
+var commands = map[string]struct {
+	state  int
+	uid    bool // Allowed with the UID prefix.
+	handle handlerFunc
+}{
+	"CAPABILITY":   {stateAny, false, (*session).capability},
+	"NOOP":         {stateAny, false, (*session).noop},
+	"LOGIN":        {stateNotAuthenticated, false, (*session).login},
+	"AUTHENTICATE": {stateNotAuthenticated, false, unsupported},
+	"STARTTLS":     {stateNotAuthenticated, false, unsupported},
+	"SELECT":       {stateAuthenticated, false, (*session).selectFolder},
+	"EXAMINE":      {stateAuthenticated, false, (*session).selectFolder},
+	"CREATE":       {stateAuthenticated, false, (*session).create},
+	"DELETE":       {stateAuthenticated, false, (*session).delete},
+	"RENAME":       {stateAuthenticated, false, (*session).rename},
+	"SUBSCRIBE":    {stateAuthenticated, false, (*session).noop},
+	"UNSUBSCRIBE":  {stateAuthenticated, false, (*session).noop},
+	"LIST":         {stateAuthenticated, false, (*session).list},
+	"LSUB":         {stateAuthenticated, false, (*session).list},
+	"STATUS":       {stateAuthenticated, false, (*session).status},
+	"APPEND":       {stateAuthenticated, false, (*session).appendMessage},
+	"CHECK":        {stateSelected, false, (*session).noop},
+	"CLOSE":        {stateSelected, false, (*session).close},
+	"UNSELECT":     {stateSelected, false, (*session).close},
+	"EXPUNGE":      {stateSelected, true, (*session).expungeCommand},
+	"SEARCH":       {stateSelected, true, (*session).search},
+	"FETCH":        {stateSelected, true, (*session).fetch},
+	"STORE":        {stateSelected, true, (*session).store},
+	"COPY":         {stateSelected, true, (*session).copy},
+	"MOVE":         {stateSelected, true, (*session).copy},
+}
+
+// handle executes a command, returning true if the connection should be closed.
+func (s *session) handle(tag string, fields []any) bool {
+	name, _ := stringField(first(fields))
+	name = strings.ToUpper(name)
+	args := fields[min(1, len(fields)):]
+	if name == "LOGOUT" {
+		s.untagged("BYE Logging out")
+		fmt.Fprintf(s.w, "%s OK LOGOUT completed\r\n", tag)
+		return true
+	}
+	var uid bool
+	if name == "UID" {
+		uid = true
+		name, _ = stringField(first(args))
+		name, args = strings.ToUpper(name), args[min(1, len(args)):]
+	}
+
+	cmd, ok := commands[name]
+	var err error
+	var code string
+	switch {
+	case !ok || (uid && !cmd.uid):
+		err = bad("Unknown command")
+	case cmd.state == stateNotAuthenticated && s.authenticated:
+		err = bad("Already authenticated")
+	case cmd.state >= stateAuthenticated && !s.authenticated:
+		err = bad("Not authenticated")
+	case cmd.state == stateSelected && s.selected == "":
+		err = bad("No folder selected")
+	default:
+		// The handlers need the command name for commands sharing a handler.
+		code, err = cmd.handle(s, uid, append([]any{name}, args...))
+	}
+
+	var se *statusError
+	switch {
+	case err == nil && code != "":
+		fmt.Fprintf(s.w, "%s OK [%s] %s completed\r\n", tag, code, name)
+	case err == nil:
+		fmt.Fprintf(s.w, "%s OK %s completed\r\n", tag, name)
+	case errors.As(err, &se):
+		fmt.Fprintf(s.w, "%s %s %s\r\n", tag, se.status, se.text)
+	case errors.Is(err, ErrNoSuchFolder):
+		fmt.Fprintf(s.w, "%s NO [TRYCREATE] No such folder\r\n", tag)
+	default:
+		fmt.Fprintf(s.w, "%s NO %s\r\n", tag, strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error()))
+	}
+	return false
+}
+
+func first(fields []any) any {
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields[0]
+}
+
+func (s *session) untagged(format string, args ...any) {
+	fmt.Fprintf(s.w, "* "+format+"\r\n", args...)
+}
+
+// stringArgs returns the n string arguments following the command name.
+func stringArgs(args []any, n int) ([]string, error) {
+	if len(args)-1 < n {
+		return nil, bad("Missing arguments")
+	}
+	strs := make([]string, n)
+	for i := range strs {
+		str, ok := stringField(args[i+1])
+		if !ok {
+			return nil, bad("Invalid arguments")
+		}
+		strs[i] = str
+	}
+	return strs, nil
+}
+
+// folderName normalizes the folder name given by the client.
+func folderName(name string) string {
+	if strings.EqualFold(name, "INBOX") {
+		return "INBOX"
+	}
+	return strings.TrimSuffix(name, delimiter)
+}
+
+func unsupported(s *session, _ bool, _ []any) (string, error) { return "", no("Not supported") }
+
+func (s *session) capability(_ bool, _ []any) (string, error) {
+	s.untagged("CAPABILITY %s", capabilities)
+	return "", nil
+}
+
+func (s *session) noop(_ bool, _ []any) (string, error) {
+	if s.selected != "" {
+		s.poll()
+	}
+	return "", nil
+}
+
+func (s *session) login(_ bool, args []any) (string, error) {
+	creds, err := stringArgs(args, 2)
+	if err != nil {
+		return "", err
+	}
+	if !s.backend.Authenticate(creds[0], creds[1]) {
+		time.Sleep(time.Second) // Slow down password guessing.
+		return "", no("[AUTHENTICATIONFAILED] Invalid credentials")
+	}
+	s.authenticated = true
+	return "CAPABILITY " + capabilities, nil
+}
+
+func (s *session) selectFolder(_ bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 1)
+	if err != nil {
+		return "", err
+	}
+	s.selected, s.msgs, s.deleted = "", nil, nil
+	folder := folderName(strs[0])
+	status, msgs, err := s.backend.Messages(folder)
+	if errors.Is(err, ErrNoSuchFolder) {
+		return "", no("No such folder")
+	} else if err != nil {
+		return "", err
+	}
+	s.selected, s.readOnly, s.validity = folder, args[0] == "EXAMINE", status.UIDValidity
+	s.msgs, s.deleted = msgs, make(map[uint32]bool)
+
+	s.untagged(`FLAGS (\Seen \Deleted)`)
+	s.untagged(`OK [PERMANENTFLAGS (\Seen \Deleted)] Limited`)
+	s.untagged("%d EXISTS", len(msgs))
+	s.untagged("0 RECENT")
+	if i := slices.IndexFunc(msgs, func(m Message) bool { return !m.Seen }); i >= 0 {
+		s.untagged("OK [UNSEEN %d] First unseen", i+1)
+	}
+	s.untagged("OK [UIDVALIDITY %d] UIDs valid", status.UIDValidity)
+	s.untagged("OK [UIDNEXT %d] Predicted next UID", status.UIDNext)
+	if s.readOnly {
+		return "READ-ONLY", nil
+	}
+	return "READ-WRITE", nil
+}
+
+func (s *session) create(_ bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 1)
+	if err != nil {
+		return "", err
+	}
+	return "", s.backend.CreateFolder(folderName(strs[0]))
+}
+
+func (s *session) delete(_ bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 1)
+	if err != nil {
+		return "", err
+	}
+	return "", s.backend.DeleteFolder(folderName(strs[0]))
+}
+
+func (s *session) rename(_ bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 2)
+	if err != nil {
+		return "", err
+	}
+	return "", s.backend.RenameFolder(folderName(strs[0]), folderName(strs[1]))
+}
+
+func (s *session) list(_ bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 2)
+	if err != nil {
+		return "", err
+	}
+	ref, pattern := strs[0], strs[1]
+	if pattern == "" && args[0] == "LIST" {
+		s.untagged(`LIST (\Noselect) "%s" ""`, delimiter)
+		return "", nil
+	}
+	re, err := listPattern(ref + pattern)
+	if err != nil {
+		return "", bad("Invalid pattern")
+	}
+	folders, err := s.backend.Folders()
+	if err != nil {
+		return "", err
+	}
+	for _, f := range folders {
+		if !re.MatchString(f.Name) {
+			continue
+		}
+		attrs := append([]string{`\HasNoChildren`}, f.Attributes...)
+		if args[0] == "LSUB" {
+			attrs = nil
+		}
+		s.untagged(`%s (%s) "%s" %s`, args[0], strings.Join(attrs, " "), delimiter, quote(f.Name))
+	}
+	return "", nil
+}
+
+// listPattern compiles a LIST mailbox pattern. INBOX is matched case-insensitively.
+func listPattern(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	if strings.HasPrefix(strings.ToUpper(pattern), "INBOX") {
+		sb.WriteString("(?i:INBOX)")
+		pattern = pattern[len("INBOX"):]
+	}
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '%':
+			sb.WriteString("[^" + regexp.QuoteMeta(delimiter) + "]*")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+func (s *session) status(_ bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 1)
+	if err != nil {
+		return "", err
+	}
+	items, ok := first(args[2:]).([]any)
+	if !ok {
+		return "", bad("Missing status items")
+	}
+	folder := folderName(strs[0])
+	status, msgs, err := s.backend.Messages(folder)
+	if errors.Is(err, ErrNoSuchFolder) {
+		return "", no("No such folder")
+	} else if err != nil {
+		return "", err
+	}
+	var resp []string
+	for _, item := range items {
+		name, _ := stringField(item)
+		switch name = strings.ToUpper(name); name {
+		case "MESSAGES":
+			resp = append(resp, fmt.Sprintf("MESSAGES %d", len(msgs)))
+		case "RECENT":
+			resp = append(resp, "RECENT 0")
+		case "UIDNEXT":
+			resp = append(resp, fmt.Sprintf("UIDNEXT %d", status.UIDNext))
+		case "UIDVALIDITY":
+			resp = append(resp, fmt.Sprintf("UIDVALIDITY %d", status.UIDValidity))
+		case "UNSEEN":
+			var n int
+			for _, m := range msgs {
+				if !m.Seen {
+					n++
+				}
+			}
+			resp = append(resp, fmt.Sprintf("UNSEEN %d", n))
+		default:
+			return "", bad("Unknown status item '%s'", name)
+		}
+	}
+	s.untagged("STATUS %s (%s)", quote(folder), strings.Join(resp, " "))
+	return "", nil
+}
+
+func (s *session) appendMessage(_ bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 1)
+	if err != nil {
+		return "", err
+	}
+	// APPEND mailbox [(flags)] [date-time] message
+	var seen bool
+	rest := args[2:]
+	if flags, ok := first(rest).([]any); ok {
+		seen = hasFlag(flags, `\Seen`)
+		rest = rest[1:]
+	}
+	if len(rest) == 2 {
+		rest = rest[1:] // The internal date is given by the message's Date header.
+	}
+	data, ok := stringField(first(rest))
+	if len(rest) != 1 || !ok {
+		return "", bad("Invalid arguments")
+	}
+	return "", s.backend.Append(folderName(strs[0]), []byte(data), seen)
+}
+
+func (s *session) close(_ bool, args []any) (string, error) {
+	if args[0] == "CLOSE" && !s.readOnly {
+		s.expunge(true, nil)
+	}
+	s.selected, s.msgs, s.deleted = "", nil, nil
+	return "", nil
+}
+
+func (s *session) expungeCommand(uid bool, args []any) (string, error) {
+	if s.readOnly {
+		return "", no("Folder is read-only")
+	}
+	var set []seqRange
+	if uid {
+		strs, err := stringArgs(args, 1)
+		if err != nil {
+			return "", err
+		}
+		if set, err = parseSeqSet(strs[0]); err != nil {
+			return "", bad("%v", err)
+		}
+	}
+	return "", s.expunge(false, set)
+}
+
+// expunge removes the messages flagged as deleted (limited to the given UIDs, if any).
+func (s *session) expunge(silent bool, uids []seqRange) error {
+	var err error
+	for i := len(s.msgs) - 1; i >= 0; i-- {
+		m := s.msgs[i]
+		if !s.deleted[m.UID] || (uids != nil && !containsSeq(uids, m.UID, s.largestUID())) {
+			continue
+		}
+		if err = s.backend.Expunge(s.selected, m.UID); err != nil {
+			continue
+		}
+		s.remove(i, silent)
+	}
+	return err
+}
+
+// remove removes the message with the given index from the session state.
+func (s *session) remove(i int, silent bool) {
+	delete(s.deleted, s.msgs[i].UID)
+	s.msgs = slices.Delete(s.msgs, i, i+1)
+	if !silent {
+		s.untagged("%d EXPUNGE", i+1)
+	}
+}
+
+// poll reports changes to the selected folder made by others.
+func (s *session) poll() {
+	status, msgs, err := s.backend.Messages(s.selected)
+	if err != nil {
+		return
+	}
+	if status.UIDValidity != s.validity {
+		// The folder was re-created. The client must re-synchronize.
+		s.untagged("BYE Folder state changed")
+		s.conn.Close()
+		return
+	}
+	current := make(map[uint32]Message, len(msgs))
+	for _, m := range msgs {
+		current[m.UID] = m
+	}
+	for i := len(s.msgs) - 1; i >= 0; i-- {
+		if _, ok := current[s.msgs[i].UID]; !ok {
+			s.remove(i, false)
+		}
+	}
+	for i, m := range s.msgs {
+		if current[m.UID].Seen != m.Seen {
+			s.msgs[i].Seen = current[m.UID].Seen
+			s.untagged("%d FETCH (FLAGS (%s))", i+1, s.flags(s.msgs[i]))
+		}
+	}
+	if len(msgs) != len(s.msgs) {
+		s.msgs = msgs
+		s.untagged("%d EXISTS", len(msgs))
+		s.untagged("0 RECENT")
+	}
+}
+
+func (s *session) largestUID() uint32 {
+	if len(s.msgs) == 0 {
+		return 0
+	}
+	return s.msgs[len(s.msgs)-1].UID
+}
+
+// resolve returns the indices of the messages in the sequence set (of UIDs, if uid is true).
+func (s *session) resolve(str string, uid bool) ([]int, error) {
+	set, err := parseSeqSet(str)
+	if err != nil {
+		return nil, bad("%v", err)
+	}
+	var idx []int
+	for i, m := range s.msgs {
+		if uid && containsSeq(set, m.UID, s.largestUID()) || !uid && containsSeq(set, uint32(i+1), uint32(len(s.msgs))) {
+			idx = append(idx, i)
+		}
+	}
+	return idx, nil
+}
+
+func (s *session) flags(m Message) string {
+	var flags []string
+	if m.Seen {
+		flags = append(flags, `\Seen`)
+	}
+	if s.deleted[m.UID] {
+		flags = append(flags, `\Deleted`)
+	}
+	return strings.Join(flags, " ")
+}
+
+func hasFlag(flags []any, flag string) bool {
+	for _, f := range flags {
+		if str, ok := stringField(f); ok && strings.EqualFold(str, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *session) store(uid bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 2)
+	if err != nil {
+		return "", err
+	}
+	if s.readOnly {
+		return "", no("Folder is read-only")
+	}
+	idx, err := s.resolve(strs[0], uid)
+	if err != nil {
+		return "", err
+	}
+	op := strings.ToUpper(strs[1])
+	silent := strings.HasSuffix(op, ".SILENT")
+	op = strings.TrimSuffix(op, ".SILENT")
+	if op != "FLAGS" && op != "+FLAGS" && op != "-FLAGS" {
+		return "", bad("Invalid store operation")
+	}
+	var flags []any
+	for _, f := range args[3:] {
+		if list, ok := f.([]any); ok {
+			flags = append(flags, list...)
+		} else {
+			flags = append(flags, f)
+		}
+	}
+	seen, del := hasFlag(flags, `\Seen`), hasFlag(flags, `\Deleted`)
+
+	for _, i := range idx {
+		m := &s.msgs[i]
+		wantSeen, wantDel := seen, del
+		switch op {
+		case "+FLAGS":
+			wantSeen, wantDel = m.Seen || seen, s.deleted[m.UID] || del
+		case "-FLAGS":
+			wantSeen, wantDel = m.Seen && !seen, s.deleted[m.UID] && !del
+		}
+		if wantSeen != m.Seen {
+			if err := s.backend.SetSeen(s.selected, m.UID, wantSeen); err != nil {
+				return "", err
+			}
+			m.Seen = wantSeen
+		}
+		if wantDel {
+			s.deleted[m.UID] = true
+		} else {
+			delete(s.deleted, m.UID)
+		}
+		if silent {
+			continue
+		}
+		if uid {
+			s.untagged("%d FETCH (UID %d FLAGS (%s))", i+1, m.UID, s.flags(*m))
+		} else {
+			s.untagged("%d FETCH (FLAGS (%s))", i+1, s.flags(*m))
+		}
+	}
+	return "", nil
+}
+
+func (s *session) copy(uid bool, args []any) (string, error) {
+	strs, err := stringArgs(args, 2)
+	if err != nil {
+		return "", err
+	}
+	move := args[0] == "MOVE"
+	if move && s.readOnly {
+		return "", no("Folder is read-only")
+	}
+	idx, err := s.resolve(strs[0], uid)
+	if err != nil {
+		return "", err
+	}
+	dest := folderName(strs[1])
+	if !move {
+		for _, i := range idx {
+			if err := s.backend.Copy(s.selected, s.msgs[i].UID, dest); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	}
+	// Expunge responses must be sent in descending order for the sequence numbers to stay valid.
+	slices.Reverse(idx)
+	for _, i := range idx {
+		if err := s.backend.Move(s.selected, s.msgs[i].UID, dest); err != nil {
+			return "", err
+		}
+		s.remove(i, false)
+	}
+	return "", nil
+}
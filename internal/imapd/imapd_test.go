@@ -0,0 +1,278 @@
+package imapd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memBackend struct {
+	mu   sync.Mutex
+	next uint32
+	msgs map[string][]*memMessage
+}
+
+type memMessage struct {
+	Message
+	data []byte
+}
+
+func (b *memBackend) Authenticate(user, pass string) bool {
+	return user == "n0call" && pass == "secret"
+}
+
+func (b *memBackend) Folders() ([]Folder, error) {
+	return []Folder{{Name: "INBOX"}, {Name: "Trash", Attributes: []string{`\Trash`}}}, nil
+}
+
+func (b *memBackend) CreateFolder(string) error         { return fmt.Errorf("not supported") }
+func (b *memBackend) DeleteFolder(string) error         { return fmt.Errorf("not supported") }
+func (b *memBackend) RenameFolder(string, string) error { return fmt.Errorf("not supported") }
+
+func (b *memBackend) Messages(folder string) (Status, []Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	msgs, ok := b.msgs[folder]
+	if !ok {
+		return Status{}, nil, ErrNoSuchFolder
+	}
+	var list []Message
+	for _, m := range msgs {
+		list = append(list, m.Message)
+	}
+	return Status{UIDValidity: 1, UIDNext: b.next + 1}, list, nil
+}
+
+func (b *memBackend) find(folder string, uid uint32) (int, error) {
+	for i, m := range b.msgs[folder] {
+		if m.UID == uid {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("not found")
+}
+
+func (b *memBackend) Fetch(folder string, uid uint32) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i, err := b.find(folder, uid)
+	if err != nil {
+		return nil, err
+	}
+	return b.msgs[folder][i].data, nil
+}
+
+func (b *memBackend) SetSeen(folder string, uid uint32, seen bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i, err := b.find(folder, uid)
+	if err == nil {
+		b.msgs[folder][i].Seen = seen
+	}
+	return err
+}
+
+func (b *memBackend) Expunge(folder string, uid uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i, err := b.find(folder, uid)
+	if err == nil {
+		b.msgs[folder] = append(b.msgs[folder][:i], b.msgs[folder][i+1:]...)
+	}
+	return err
+}
+
+func (b *memBackend) Copy(folder string, uid uint32, dest string) error {
+	data, err := b.Fetch(folder, uid)
+	if err != nil {
+		return err
+	}
+	return b.Append(dest, data, false)
+}
+
+func (b *memBackend) Move(folder string, uid uint32, dest string) error {
+	if err := b.Copy(folder, uid, dest); err != nil {
+		return err
+	}
+	return b.Expunge(folder, uid)
+}
+
+func (b *memBackend) Append(folder string, data []byte, seen bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.msgs[folder]; !ok {
+		return ErrNoSuchFolder
+	}
+	b.next++
+	m := &memMessage{Message{UID: b.next, Seen: seen, Date: time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC), Size: len(data)}, data}
+	b.msgs[folder] = append(b.msgs[folder], m)
+	return nil
+}
+
+type client struct {
+	t *testing.T
+	r *bufio.Reader
+	w net.Conn
+	n int
+}
+
+// cmd sends the command, returning the untagged responses and the tagged completion.
+func (c *client) cmd(format string, args ...any) ([]string, string) {
+	c.t.Helper()
+	c.n++
+	tag := fmt.Sprintf("a%d", c.n)
+	fmt.Fprintf(c.w, tag+" "+format+"\r\n", args...)
+	var untagged []string
+	for {
+		line := c.readLine()
+		if strings.HasPrefix(line, tag+" ") {
+			return untagged, strings.TrimPrefix(line, tag+" ")
+		}
+		untagged = append(untagged, line)
+	}
+}
+
+func (c *client) readLine() string {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func TestServer(t *testing.T) {
+	backend := &memBackend{msgs: map[string][]*memMessage{"INBOX": nil, "Trash": nil}}
+	backend.Append("INBOX", []byte("From: LA5NTA <LA5NTA@winlink.org>\r\nSubject: Hello\r\nDate: Fri, 17 May 2024 12:00:00 +0000\r\n\r\nFirst message\r\n"), false)
+	backend.Append("INBOX", []byte("From: N0CALL@winlink.org\r\nSubject: Weather\r\n\r\nSecond message\r\n"), true)
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go (&Server{Backend: backend}).Serve(ln)
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &client{t: t, r: bufio.NewReader(conn), w: conn}
+	if greeting := c.readLine(); !strings.HasPrefix(greeting, "* OK") {
+		t.Fatalf("unexpected greeting: %q", greeting)
+	}
+
+	expectOK := func(untagged []string, status string) []string {
+		t.Helper()
+		if !strings.HasPrefix(status, "OK") {
+			t.Fatalf("unexpected status: %q", status)
+		}
+		return untagged
+	}
+
+	if _, status := c.cmd("SELECT INBOX"); !strings.HasPrefix(status, "BAD") {
+		t.Errorf("expected BAD before login, got %q", status)
+	}
+	if _, status := c.cmd("LOGIN n0call wrong"); !strings.HasPrefix(status, "NO") {
+		t.Errorf("expected NO for invalid credentials, got %q", status)
+	}
+	expectOK(c.cmd(`LOGIN "n0call" {6+}` + "\r\nsecret"))
+
+	resp := expectOK(c.cmd(`LIST "" "*"`))
+	if len(resp) != 2 || resp[1] != `* LIST (\HasNoChildren \Trash) "/" "Trash"` {
+		t.Errorf("unexpected LIST response: %q", resp)
+	}
+
+	resp = expectOK(c.cmd("select inbox"))
+	if !contains(resp, "* 2 EXISTS") || !contains(resp, "* OK [UNSEEN 1] First unseen") {
+		t.Errorf("unexpected SELECT response: %q", resp)
+	}
+
+	resp = expectOK(c.cmd("SEARCH UNSEEN"))
+	if len(resp) != 1 || resp[0] != "* SEARCH 1" {
+		t.Errorf("unexpected SEARCH response: %q", resp)
+	}
+	resp = expectOK(c.cmd(`UID SEARCH OR FROM "n0call" BODY "first"`))
+	if len(resp) != 1 || resp[0] != "* SEARCH 1 2" {
+		t.Errorf("unexpected UID SEARCH response: %q", resp)
+	}
+
+	resp = expectOK(c.cmd("FETCH 1 (FLAGS BODY.PEEK[HEADER.FIELDS (SUBJECT)])"))
+	if len(resp) != 4 || resp[0] != "* 1 FETCH (FLAGS () BODY[HEADER.FIELDS (SUBJECT)] {18}" || resp[1] != "Subject: Hello" {
+		t.Errorf("unexpected FETCH response: %q", resp)
+	}
+	resp = expectOK(c.cmd("UID FETCH 1 BODY[TEXT]<0.5>"))
+	if len(resp) != 2 || resp[0] != "* 1 FETCH (UID 1 BODY[TEXT]<0> {5}" || resp[1] != "First FLAGS (\\Seen))" {
+		t.Errorf("unexpected UID FETCH response: %q", resp)
+	}
+	if msgs := backend.msgs["INBOX"]; !msgs[0].Seen {
+		t.Error("expected fetched message to be marked as seen")
+	}
+
+	resp = expectOK(c.cmd(`STORE 2 -FLAGS (\Seen)`))
+	if len(resp) != 1 || resp[0] != "* 2 FETCH (FLAGS ())" {
+		t.Errorf("unexpected STORE response: %q", resp)
+	}
+	if backend.msgs["INBOX"][1].Seen {
+		t.Error("expected message to be marked as unseen")
+	}
+
+	expectOK(c.cmd(`STORE 1 +FLAGS.SILENT (\Deleted)`))
+	resp = expectOK(c.cmd("EXPUNGE"))
+	if len(resp) != 1 || resp[0] != "* 1 EXPUNGE" || len(backend.msgs["INBOX"]) != 1 {
+		t.Errorf("unexpected EXPUNGE response: %q", resp)
+	}
+
+	if _, status := c.cmd("COPY 1 Missing"); status != "NO [TRYCREATE] No such folder" {
+		t.Errorf("expected TRYCREATE, got %q", status)
+	}
+	resp = expectOK(c.cmd("MOVE 1 Trash"))
+	if len(resp) != 1 || resp[0] != "* 1 EXPUNGE" || len(backend.msgs["Trash"]) != 1 {
+		t.Errorf("unexpected MOVE response: %q", resp)
+	}
+
+	expectOK(c.cmd("APPEND INBOX (\\Seen) {20+}\r\nSubject: Appended\r\n\r\n"))
+	resp = expectOK(c.cmd("NOOP"))
+	if !contains(resp, "* 1 EXISTS") {
+		t.Errorf("expected new message to be reported, got %q", resp)
+	}
+
+	resp, status := c.cmd("LOGOUT")
+	if len(resp) != 1 || !strings.HasPrefix(resp[0], "* BYE") || !strings.HasPrefix(status, "OK") {
+		t.Errorf("unexpected LOGOUT response: %q %q", resp, status)
+	}
+}
+
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestListPattern(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"*", "Sent Items", true},
+		{"%", "a/b", false},
+		{"inbox", "INBOX", true},
+		{"Arch*", "archive", false},
+	}
+	for _, tt := range tests {
+		re, err := listPattern(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := re.MatchString(tt.name); got != tt.match {
+			t.Errorf("%q ~ %q: expected %t", tt.pattern, tt.name, tt.match)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package imapd
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// entity is a parsed MIME entity (a message or a body part).
+type entity struct {
+	header   []byte // Raw header, including the terminating empty line.
+	body     []byte
+	fields   textproto.MIMEHeader
+	children []*entity // Parts of a multipart entity.
+	message  *entity   // Encapsulated message of a message/rfc822 entity.
+}
+
+func parseEntity(raw []byte) *entity {
+	e := &entity{header: raw, body: nil}
+	for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+		if i := bytes.Index(raw, []byte(sep)); i >= 0 {
+			e.header, e.body = raw[:i+len(sep)], raw[i+len(sep):]
+			break
+		}
+	}
+	if bytes.HasPrefix(raw, []byte("\r\n")) || bytes.HasPrefix(raw, []byte("\n")) {
+		n := 1
+		if raw[0] == '\r' {
+			n = 2
+		}
+		e.header, e.body = raw[:n], raw[n:] // No header fields.
+	}
+	e.fields = parseFields(e.header)
+
+	mediaType, params := e.mediaType()
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "":
+		for _, part := range splitMultipart(e.body, params["boundary"]) {
+			e.children = append(e.children, parseEntity(part))
+		}
+	case mediaType == "message/rfc822":
+		e.message = parseEntity(e.body)
+	}
+	return e
+}
+
+// mediaType returns the media type and parameters, defaulting to text/plain.
+func (e *entity) mediaType() (string, map[string]string) {
+	mediaType, params, err := mime.ParseMediaType(e.fields.Get("Content-Type"))
+	if err != nil || mediaType == "" {
+		return "text/plain", map[string]string{"charset": "us-ascii"}
+	}
+	return mediaType, params
+}
+
+// part returns the body part with the given part number path (e.g. [2, 1]).
+func (e *entity) part(path []int) (*entity, bool) {
+	for _, n := range path {
+		if e.message != nil {
+			e = e.message // Part numbers of an encapsulated message refer to its body.
+		}
+		switch {
+		case len(e.children) > 0 && n >= 1 && n <= len(e.children):
+			e = e.children[n-1]
+		case len(e.children) == 0 && n == 1:
+			// Part 1 of a non-multipart message is the body itself.
+		default:
+			return nil, false
+		}
+	}
+	return e, true
+}
+
+// parseFields parses the header fields, ignoring malformed lines.
+func parseFields(header []byte) textproto.MIMEHeader {
+	fields := make(textproto.MIMEHeader)
+	for _, f := range splitFields(header) {
+		name, value, ok := strings.Cut(f, ":")
+		if !ok {
+			continue
+		}
+		value = strings.NewReplacer("\r\n", "", "\n", "").Replace(value)
+		fields.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return fields
+}
+
+// splitFields returns the raw header fields (including folded continuation lines and line endings).
+func splitFields(header []byte) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(string(header), "\n") {
+		switch {
+		case strings.TrimRight(line, "\r\n") == "":
+			continue
+		case (line[0] == ' ' || line[0] == '\t') && len(fields) > 0:
+			fields[len(fields)-1] += line
+		default:
+			fields = append(fields, line)
+		}
+	}
+	return fields
+}
+
+// filterFields returns the header fields with (or without, if not) the given names, terminated by an empty line.
+func filterFields(header []byte, names []string, not bool) []byte {
+	var buf bytes.Buffer
+	for _, f := range splitFields(header) {
+		name, _, _ := strings.Cut(f, ":")
+		var match bool
+		for _, n := range names {
+			match = match || strings.EqualFold(strings.TrimSpace(name), n)
+		}
+		if match != not {
+			buf.WriteString(f)
+		}
+	}
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+func splitMultipart(body []byte, boundary string) [][]byte {
+	delim := "--" + boundary
+	var parts [][]byte
+	start := -1
+	lines := bytes.SplitAfter(body, []byte("\n"))
+	offset := 0
+	for _, line := range lines {
+		trimmed := strings.TrimRight(string(line), " \t\r\n")
+		if trimmed == delim || trimmed == delim+"--" {
+			if start >= 0 {
+				end := offset
+				// The line break preceding the delimiter belongs to the delimiter.
+				if end > start && body[end-1] == '\n' {
+					end--
+					if end > start && body[end-1] == '\r' {
+						end--
+					}
+				}
+				parts = append(parts, body[start:end])
+			}
+			if trimmed == delim+"--" {
+				return parts
+			}
+			start = offset + len(line)
+		}
+		offset += len(line)
+	}
+	if start >= 0 && start < len(body) {
+		parts = append(parts, body[start:]) // Missing close delimiter.
+	}
+	return parts
+}
+
+// envelope formats the IMAP envelope structure of the message header.
+func envelope(fields textproto.MIMEHeader) string {
+	from := addressList(fields.Get("From"))
+	sender, replyTo := addressList(fields.Get("Sender")), addressList(fields.Get("Reply-To"))
+	if sender == "NIL" {
+		sender = from
+	}
+	if replyTo == "NIL" {
+		replyTo = from
+	}
+	return fmt.Sprintf("(%s %s %s %s %s %s %s %s %s %s)",
+		nstring(fields.Get("Date")),
+		nstring(fields.Get("Subject")),
+		from, sender, replyTo,
+		addressList(fields.Get("To")),
+		addressList(fields.Get("Cc")),
+		addressList(fields.Get("Bcc")),
+		nstring(fields.Get("In-Reply-To")),
+		nstring(fields.Get("Message-Id")),
+	)
+}
+
+func addressList(value string) string {
+	if value == "" {
+		return "NIL"
+	}
+	addrs, err := new(mail.AddressParser).ParseList(value)
+	if err != nil || len(addrs) == 0 {
+		return "NIL"
+	}
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for _, addr := range addrs {
+		mailbox, host, _ := strings.Cut(addr.Address, "@")
+		name := addr.Name
+		if name != "" {
+			name = mime.QEncoding.Encode("utf-8", name)
+		}
+		fmt.Fprintf(&sb, "(%s NIL %s %s)", nstring(name), nstring(mailbox), nstring(host))
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// bodyStructure formats the IMAP body structure of the entity, including extension data if ext is true.
+func bodyStructure(e *entity, ext bool) string {
+	mediaType, params := e.mediaType()
+	typ, subtype, _ := strings.Cut(strings.ToUpper(mediaType), "/")
+	if len(e.children) > 0 {
+		var sb strings.Builder
+		sb.WriteByte('(')
+		for _, child := range e.children {
+			sb.WriteString(bodyStructure(child, ext))
+		}
+		sb.WriteString(" " + quote(subtype))
+		if ext {
+			fmt.Fprintf(&sb, " %s %s NIL NIL", paramList(params), disposition(e))
+		}
+		sb.WriteByte(')')
+		return sb.String()
+	}
+
+	encoding := strings.ToUpper(e.fields.Get("Content-Transfer-Encoding"))
+	if encoding == "" {
+		encoding = "7BIT"
+	}
+	s := fmt.Sprintf("(%s %s %s %s %s %s %d",
+		quote(typ), quote(subtype), paramList(params),
+		nstring(e.fields.Get("Content-Id")), nstring(e.fields.Get("Content-Description")),
+		quote(encoding), len(e.body))
+	switch {
+	case e.message != nil:
+		s += fmt.Sprintf(" %s %s %d", envelope(e.message.fields), bodyStructure(e.message, ext), lines(e.body))
+	case typ == "TEXT":
+		s += fmt.Sprintf(" %d", lines(e.body))
+	}
+	if ext {
+		s += fmt.Sprintf(" NIL %s NIL NIL", disposition(e))
+	}
+	return s + ")"
+}
+
+func paramList(params map[string]string) string {
+	if len(params) == 0 {
+		return "NIL"
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	list := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		list = append(list, quote(strings.ToUpper(k)), quote(params[k]))
+	}
+	return "(" + strings.Join(list, " ") + ")"
+}
+
+func disposition(e *entity) string {
+	disp, params, err := mime.ParseMediaType(e.fields.Get("Content-Disposition"))
+	if err != nil {
+		return "NIL"
+	}
+	return fmt.Sprintf("(%s %s)", quote(strings.ToUpper(disp)), paramList(params))
+}
+
+func lines(body []byte) int { return bytes.Count(body, []byte("\n")) }
+
+// parsePartPath parses a part number path (e.g. "2.1").
+func parsePartPath(str string) ([]int, bool) {
+	var path []int
+	for _, s := range strings.Split(str, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return nil, false
+		}
+		path = append(path, n)
+	}
+	return path, true
+}
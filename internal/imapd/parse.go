@@ -0,0 +1,267 @@
+package imapd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxLineLength    = 64 << 10
+	maxLiteralLength = 20 << 20
+)
+
+var errLineTooLong = errors.New("line too long")
+
+// syntaxError is a malformed command. The connection remains usable.
+type syntaxError string
+
+func (e syntaxError) Error() string { return string(e) }
+
+func syntaxErrorf(format string, args ...any) error { return syntaxError(fmt.Sprintf(format, args...)) }
+
+// parser reads and tokenizes client commands.
+//
+// Commands are parsed into a list of fields, where each field is either a string (atom, quoted
+// string or literal) or a parenthesized list ([]any). Atoms containing brackets (e.g.
+// BODY[HEADER.FIELDS (FROM TO)]<0.100>) are returned as a single string.
+type parser struct {
+	r    *bufio.Reader
+	cont func() error // Requests a synchronizing literal from the client.
+	line string
+	pos  int
+}
+
+func (p *parser) readLine() error {
+	var line []byte
+	for {
+		chunk, isPrefix, err := p.r.ReadLine()
+		if err != nil {
+			return err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxLineLength {
+			return errLineTooLong
+		}
+		if !isPrefix {
+			break
+		}
+	}
+	p.line, p.pos = string(line), 0
+	return nil
+}
+
+// readCommand reads the next command, returning the tag and the remaining fields.
+func (p *parser) readCommand() (string, []any, error) {
+	if err := p.readLine(); err != nil {
+		return "", nil, err
+	}
+	fields, err := p.parseList(0)
+	var tag string
+	if len(fields) > 0 {
+		tag, _ = fields[0].(string)
+	}
+	switch {
+	case err != nil:
+		return tag, nil, err
+	case tag == "":
+		return "", nil, syntaxErrorf("invalid tag")
+	}
+	return tag, fields[1:], nil
+}
+
+// parseList parses fields until the closing character (or end of command if zero).
+func (p *parser) parseList(closing byte) ([]any, error) {
+	var fields []any
+	for {
+		for p.pos < len(p.line) && p.line[p.pos] == ' ' {
+			p.pos++
+		}
+		if p.pos >= len(p.line) {
+			if closing != 0 {
+				return fields, syntaxErrorf("unterminated list")
+			}
+			return fields, nil
+		}
+		switch c := p.line[p.pos]; {
+		case closing != 0 && c == closing:
+			p.pos++
+			return fields, nil
+		case c == '(':
+			p.pos++
+			list, err := p.parseList(')')
+			if err != nil {
+				return fields, err
+			}
+			fields = append(fields, list)
+		case c == '"':
+			str, err := p.parseQuoted()
+			if err != nil {
+				return fields, err
+			}
+			fields = append(fields, str)
+		case c == '{':
+			str, err := p.parseLiteral()
+			if err != nil {
+				return fields, err
+			}
+			fields = append(fields, str)
+		case c == ')':
+			return fields, syntaxErrorf("unexpected ')'")
+		default:
+			fields = append(fields, p.parseAtom())
+		}
+	}
+}
+
+func (p *parser) parseQuoted() (string, error) {
+	var sb strings.Builder
+	for p.pos++; p.pos < len(p.line); p.pos++ {
+		switch c := p.line[p.pos]; c {
+		case '\\':
+			if p.pos++; p.pos >= len(p.line) {
+				return "", syntaxErrorf("unterminated quoted string")
+			}
+			sb.WriteByte(p.line[p.pos])
+		case '"':
+			p.pos++
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", syntaxErrorf("unterminated quoted string")
+}
+
+func (p *parser) parseLiteral() (string, error) {
+	end := strings.IndexByte(p.line[p.pos:], '}')
+	if end < 0 || p.pos+end != len(p.line)-1 {
+		return "", syntaxErrorf("invalid literal")
+	}
+	spec := p.line[p.pos+1 : p.pos+end]
+	nonSync := strings.HasSuffix(spec, "+")
+	n, err := strconv.Atoi(strings.TrimSuffix(spec, "+"))
+	if err != nil || n < 0 {
+		return "", syntaxErrorf("invalid literal")
+	}
+	if n > maxLiteralLength {
+		return "", fmt.Errorf("literal too large")
+	}
+	if !nonSync {
+		if err := p.cont(); err != nil {
+			return "", err
+		}
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(p.r, buf); err != nil {
+		return "", err
+	}
+	// The command continues on the line following the literal.
+	if err := p.readLine(); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (p *parser) parseAtom() string {
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.line); p.pos++ {
+		switch p.line[p.pos] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ' ', '(', ')':
+			if depth <= 0 {
+				return p.line[start:p.pos]
+			}
+		}
+	}
+	return p.line[start:]
+}
+
+// seqRange is an inclusive range of sequence numbers or UIDs. Zero denotes "*".
+type seqRange struct{ start, stop uint32 }
+
+// parseSeqSet parses a sequence set (e.g. "1:5,7,9:*").
+func parseSeqSet(str string) ([]seqRange, error) {
+	if str == "" {
+		return nil, syntaxErrorf("empty sequence set")
+	}
+	var set []seqRange
+	for _, item := range strings.Split(str, ",") {
+		a, b, isRange := strings.Cut(item, ":")
+		start, err := parseSeqNumber(a)
+		if err != nil {
+			return nil, err
+		}
+		stop := start
+		if isRange {
+			if stop, err = parseSeqNumber(b); err != nil {
+				return nil, err
+			}
+		}
+		set = append(set, seqRange{start, stop})
+	}
+	return set, nil
+}
+
+func parseSeqNumber(str string) (uint32, error) {
+	if str == "*" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(str, 10, 32)
+	if err != nil || n == 0 {
+		return 0, syntaxErrorf("invalid sequence number '%s'", str)
+	}
+	return uint32(n), nil
+}
+
+// containsSeq reports whether n is in the set, given the largest number in use (the value of "*").
+func containsSeq(set []seqRange, n, largest uint32) bool {
+	for _, r := range set {
+		start, stop := r.start, r.stop
+		if start == 0 {
+			start = largest
+		}
+		if stop == 0 {
+			stop = largest
+		}
+		if start > stop {
+			start, stop = stop, start
+		}
+		if n >= start && n <= stop {
+			return true
+		}
+	}
+	return false
+}
+
+// quote formats str as an IMAP string (quoted, or as a literal if needed).
+func quote(str string) string {
+	for i := 0; i < len(str); i++ {
+		if c := str[i]; c == '\r' || c == '\n' || c >= 0x80 {
+			return literal(str)
+		}
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(str) + `"`
+}
+
+// nstring formats str as an IMAP nstring (NIL if empty).
+func nstring(str string) string {
+	if str == "" {
+		return "NIL"
+	}
+	return quote(str)
+}
+
+func literal(str string) string { return fmt.Sprintf("{%d}\r\n%s", len(str), str) }
+
+// stringField returns the field as a string.
+func stringField(f any) (string, bool) {
+	str, ok := f.(string)
+	return str, ok
+}
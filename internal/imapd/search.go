@@ -0,0 +1,288 @@
+package imapd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const searchDateLayout = "2-Jan-2006"
+
+// searchMsg is a message being matched against search criteria.
+type searchMsg struct {
+	s    *session
+	seq  int
+	msg  Message
+	root *entity // Lazily fetched by content().
+	err  error
+}
+
+func (m *searchMsg) content() *entity {
+	if m.root == nil && m.err == nil {
+		var raw []byte
+		if raw, m.err = m.s.backend.Fetch(m.s.selected, m.msg.UID); m.err == nil {
+			m.root = parseEntity(raw)
+		}
+	}
+	if m.root == nil {
+		return parseEntity(nil)
+	}
+	return m.root
+}
+
+type matcher func(m *searchMsg) bool
+
+func (s *session) search(uid bool, args []any) (string, error) {
+	criteria := args[1:]
+	if str, ok := stringField(first(criteria)); ok && strings.EqualFold(str, "CHARSET") {
+		if len(criteria) < 2 {
+			return "", bad("Missing charset")
+		}
+		criteria = criteria[2:] // Strings are matched as UTF-8 regardless.
+	}
+	if len(criteria) == 0 {
+		return "", bad("Missing search criteria")
+	}
+	match, err := s.compileSearch(&criteria)
+	for err == nil && len(criteria) > 0 {
+		var next matcher
+		if next, err = s.compileSearch(&criteria); err == nil {
+			match = and(match, next)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var results []string
+	for i, msg := range s.msgs {
+		if !match(&searchMsg{s: s, seq: i + 1, msg: msg}) {
+			continue
+		}
+		if uid {
+			results = append(results, strconv.FormatUint(uint64(msg.UID), 10))
+		} else {
+			results = append(results, strconv.Itoa(i+1))
+		}
+	}
+	s.untagged("%s", strings.TrimSpace("SEARCH "+strings.Join(results, " ")))
+	return "", nil
+}
+
+func and(a, b matcher) matcher { return func(m *searchMsg) bool { return a(m) && b(m) } }
+
+func constant(v bool) matcher { return func(*searchMsg) bool { return v } }
+
+// compileSearch compiles the next search key of the criteria.
+func (s *session) compileSearch(criteria *[]any) (matcher, error) {
+	next := func() (any, bool) {
+		if len(*criteria) == 0 {
+			return nil, false
+		}
+		f := (*criteria)[0]
+		*criteria = (*criteria)[1:]
+		return f, true
+	}
+	nextString := func() (string, error) {
+		f, _ := next()
+		str, ok := stringField(f)
+		if !ok {
+			return "", bad("Missing search argument")
+		}
+		return str, nil
+	}
+
+	f, _ := next()
+	if list, ok := f.([]any); ok {
+		if len(list) == 0 {
+			return nil, bad("Empty search list")
+		}
+		match := constant(true)
+		for len(list) > 0 {
+			m, err := s.compileSearch(&list)
+			if err != nil {
+				return nil, err
+			}
+			match = and(match, m)
+		}
+		return match, nil
+	}
+	key, _ := stringField(f)
+
+	switch key = strings.ToUpper(key); key {
+	case "ALL":
+		return constant(true), nil
+	case "SEEN":
+		return func(m *searchMsg) bool { return m.msg.Seen }, nil
+	case "UNSEEN":
+		return func(m *searchMsg) bool { return !m.msg.Seen }, nil
+	case "DELETED":
+		return func(m *searchMsg) bool { return s.deleted[m.msg.UID] }, nil
+	case "UNDELETED":
+		return func(m *searchMsg) bool { return !s.deleted[m.msg.UID] }, nil
+	case "ANSWERED", "DRAFT", "FLAGGED", "RECENT", "NEW":
+		return constant(false), nil
+	case "UNANSWERED", "UNDRAFT", "UNFLAGGED", "OLD":
+		return constant(true), nil
+	case "KEYWORD":
+		_, err := nextString()
+		return constant(false), err
+	case "UNKEYWORD":
+		_, err := nextString()
+		return constant(true), err
+	case "FROM", "TO", "CC", "BCC", "SUBJECT":
+		value, err := nextString()
+		if err != nil {
+			return nil, err
+		}
+		return headerContains(key, value), nil
+	case "HEADER":
+		name, err := nextString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := nextString()
+		if err != nil {
+			return nil, err
+		}
+		return headerContains(name, value), nil
+	case "BODY", "TEXT":
+		value, err := nextString()
+		if err != nil {
+			return nil, err
+		}
+		value = strings.ToLower(value)
+		return func(m *searchMsg) bool {
+			e := m.content()
+			if key == "TEXT" && strings.Contains(strings.ToLower(decodeHeader(string(e.header))), value) {
+				return true
+			}
+			return strings.Contains(strings.ToLower(bodyText(e)), value)
+		}, nil
+	case "BEFORE", "ON", "SINCE", "SENTBEFORE", "SENTON", "SENTSINCE":
+		str, err := nextString()
+		if err != nil {
+			return nil, err
+		}
+		date, err := time.Parse(searchDateLayout, str)
+		if err != nil {
+			return nil, bad("Invalid date '%s'", str)
+		}
+		sent := strings.HasPrefix(key, "SENT")
+		return func(m *searchMsg) bool {
+			t := m.msg.Date
+			if sent {
+				if hd, err := mail.ParseDate(m.content().fields.Get("Date")); err == nil {
+					t = hd
+				}
+			}
+			day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			switch strings.TrimPrefix(key, "SENT") {
+			case "BEFORE":
+				return day.Before(date)
+			case "ON":
+				return day.Equal(date)
+			default:
+				return !day.Before(date)
+			}
+		}, nil
+	case "LARGER", "SMALLER":
+		str, err := nextString()
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, bad("Invalid size '%s'", str)
+		}
+		if key == "LARGER" {
+			return func(m *searchMsg) bool { return m.msg.Size > n }, nil
+		}
+		return func(m *searchMsg) bool { return m.msg.Size < n }, nil
+	case "UID":
+		str, err := nextString()
+		if err != nil {
+			return nil, err
+		}
+		set, err := parseSeqSet(str)
+		if err != nil {
+			return nil, bad("%v", err)
+		}
+		return func(m *searchMsg) bool { return containsSeq(set, m.msg.UID, s.largestUID()) }, nil
+	case "NOT":
+		match, err := s.compileSearch(criteria)
+		if err != nil {
+			return nil, err
+		}
+		return func(m *searchMsg) bool { return !match(m) }, nil
+	case "OR":
+		a, err := s.compileSearch(criteria)
+		if err != nil {
+			return nil, err
+		}
+		b, err := s.compileSearch(criteria)
+		if err != nil {
+			return nil, err
+		}
+		return func(m *searchMsg) bool { return a(m) || b(m) }, nil
+	case "":
+		return nil, bad("Invalid search criteria")
+	default:
+		set, err := parseSeqSet(key)
+		if err != nil {
+			return nil, bad("Unknown search key '%s'", key)
+		}
+		return func(m *searchMsg) bool { return containsSeq(set, uint32(m.seq), uint32(len(s.msgs))) }, nil
+	}
+}
+
+func headerContains(name, value string) matcher {
+	value = strings.ToLower(value)
+	return func(m *searchMsg) bool {
+		for _, v := range m.content().fields.Values(name) {
+			if strings.Contains(strings.ToLower(decodeHeader(v)), value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func decodeHeader(value string) string {
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(value); err == nil {
+		return decoded
+	}
+	return value
+}
+
+// bodyText returns the decoded text parts of the entity.
+func bodyText(e *entity) string {
+	if e.message != nil {
+		return bodyText(e.message)
+	}
+	if len(e.children) > 0 {
+		var sb strings.Builder
+		for _, child := range e.children {
+			sb.WriteString(bodyText(child))
+		}
+		return sb.String()
+	}
+	if mediaType, _ := e.mediaType(); !strings.HasPrefix(mediaType, "text/") {
+		return ""
+	}
+	var r io.Reader = bytes.NewReader(e.body)
+	switch strings.ToLower(e.fields.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	text, _ := io.ReadAll(r)
+	return string(text)
+}
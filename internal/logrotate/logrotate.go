@@ -0,0 +1,311 @@
+// Package logrotate implements a log file writer with size and age based
+// rotation, keeping a configurable number of previous generations.
+//
+// Generations are named after the log file with a numeric suffix, where 1 is
+// the most recent (e.g. pat.log.1, pat.log.2.gz).
+//
+// The same log file may be written by several processes. A File follows
+// rotations made by other processes by reopening the path when it no longer
+// refers to the open file.
+package logrotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	MaxSize  int64         // Rotate when the file exceeds this size (in bytes). Zero means no limit.
+	MaxAge   time.Duration // Rotate when the current generation is older than this. Zero means no limit.
+	Keep     int           // Number of previous generations to keep.
+	Compress bool          // Compress previous generations with gzip.
+	NoRotate bool          // Never rotate the file, only follow rotations made by other processes.
+}
+
+// File is an append-only log file that is rotated according to its Options.
+//
+// It is safe for concurrent use.
+type File struct {
+	path string
+	opts Options
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time
+}
+
+// Open opens (or creates) the log file at path for appending.
+func Open(path string, opts Options) (*File, error) {
+	f := &File{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	if f.opts.NoRotate || !f.shouldRotate(0) {
+		return f, nil
+	}
+	if err := f.Rotate(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.started = file, info.Size(), time.Now()
+	if f.size > 0 {
+		// We can't know when an existing file was started. The last
+		// modification time is the best approximation we have.
+		f.started = info.ModTime()
+	}
+	return nil
+}
+
+// Name returns the path of the current log file.
+func (f *File) Name() string { return f.path }
+
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if err := f.follow(); err != nil {
+		return 0, err
+	}
+	if !f.opts.NoRotate && f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// follow reopens the file if the path no longer refers to it (e.g. after
+// being rotated by another process), and updates the size to include writes
+// made by other processes.
+func (f *File) follow() error {
+	info, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	if current, err := os.Stat(f.path); err == nil && os.SameFile(info, current) {
+		f.size = info.Size()
+		return nil
+	}
+	f.file.Close()
+	f.file = nil
+	return f.open()
+}
+
+func (f *File) shouldRotate(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	switch {
+	case f.opts.MaxSize > 0 && f.size+n > f.opts.MaxSize:
+		return true
+	case f.opts.MaxAge > 0 && time.Since(f.started) > f.opts.MaxAge:
+		return true
+	default:
+		return false
+	}
+}
+
+// Rotate starts a new generation of the log file.
+//
+// The current file is shifted into the list of previous generations, or
+// truncated if no previous generations are kept. Empty files are not rotated,
+// and neither are files opened with NoRotate.
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	if err := f.follow(); err != nil {
+		return err
+	}
+	if f.opts.NoRotate || f.size == 0 {
+		return nil
+	}
+	return f.rotate()
+}
+
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	if f.opts.Keep <= 0 {
+		if err := os.Truncate(f.path, 0); err != nil {
+			return err
+		}
+		return f.open()
+	}
+
+	// Drop the oldest generation and shift the remaining ones.
+	for _, ext := range []string{"", ".gz"} {
+		if err := os.Remove(f.generation(f.opts.Keep) + ext); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for i := f.opts.Keep - 1; i > 0; i-- {
+		for _, ext := range []string{"", ".gz"} {
+			err := os.Rename(f.generation(i)+ext, f.generation(i+1)+ext)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	if err := os.Rename(f.path, f.generation(1)); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	if f.opts.Compress {
+		return compress(f.generation(1))
+	}
+	return nil
+}
+
+func (f *File) generation(i int) string { return fmt.Sprintf("%s.%d", f.path, i) }
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// OpenAll opens the log file at path for reading, preceded by all of its
+// previous generations (oldest first). Compressed generations are
+// decompressed.
+//
+// If neither the log file nor any previous generations exist, the error of
+// opening path is returned.
+func OpenAll(path string) (io.ReadCloser, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	gens := map[int]string{}
+	prefix := filepath.Base(path) + "."
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(suffix, ".gz"))
+		if err != nil || n < 1 || e.IsDir() {
+			continue
+		}
+		// Prefer the uncompressed file if interrupted while compressing.
+		if _, ok := gens[n]; !ok || !strings.HasSuffix(suffix, ".gz") {
+			gens[n] = filepath.Join(filepath.Dir(path), e.Name())
+		}
+	}
+	order := make([]int, 0, len(gens))
+	for n := range gens {
+		order = append(order, n)
+	}
+	slices.Sort(order)
+	slices.Reverse(order)
+
+	r := &multiReadCloser{}
+	for _, n := range order {
+		if err := r.open(gens[n]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			r.Close()
+			return nil, err
+		}
+	}
+	if err := r.open(path); err != nil && (len(r.readers) == 0 || !errors.Is(err, os.ErrNotExist)) {
+		r.Close()
+		return nil, err
+	}
+	r.Reader = io.MultiReader(r.readers...)
+	return r, nil
+}
+
+type multiReadCloser struct {
+	io.Reader
+	readers []io.Reader
+	closers []io.Closer
+}
+
+func (r *multiReadCloser) open(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	r.closers = append(r.closers, file)
+	if !strings.HasSuffix(path, ".gz") {
+		r.readers = append(r.readers, file)
+		return nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	r.readers = append(r.readers, gz)
+	return nil
+}
+
+func (r *multiReadCloser) Close() error {
+	var errs []error
+	for _, c := range r.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// compress replaces the file at path with a gzip compressed copy (path.gz).
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o666)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	w := gzip.NewWriter(dst)
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
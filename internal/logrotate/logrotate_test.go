@@ -0,0 +1,166 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pat.log")
+	f, err := Open(path, Options{MaxSize: 10, Keep: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := readFile(t, path); got != "fourth\n" {
+		t.Errorf("current generation: got %q", got)
+	}
+	if got := readGzip(t, path+".1.gz"); got != "third\n" {
+		t.Errorf("generation 1: got %q", got)
+	}
+	if got := readGzip(t, path+".2.gz"); got != "second\n" {
+		t.Errorf("generation 2: got %q", got)
+	}
+	if _, err := os.Stat(path + ".3.gz"); !os.IsNotExist(err) {
+		t.Errorf("expected generation 3 to be removed, got %v", err)
+	}
+}
+
+func TestOpenAll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pat.log")
+	if _, err := OpenAll(path); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+
+	f, err := Open(path, Options{MaxSize: 10, Keep: 3, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		f.Write([]byte(line))
+	}
+	// An uncompressed generation (e.g. with compression disabled).
+	if err := os.Remove(path + ".1.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".1", []byte("third\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenAll(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "first\nsecond\nthird\nfourth\n" {
+		t.Errorf("got %q", got)
+	}
+
+	// Previous generations are read even if the current file is missing.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	r, err = OpenAll(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+}
+
+func TestRotateKeepNone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pat.log")
+	if err := os.WriteFile(path, []byte("previous run\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("new run\n"))
+	if got := readFile(t, path); got != "new run\n" {
+		t.Errorf("got %q", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("unexpected generation 1: %v", err)
+	}
+}
+
+func TestFollowRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pat.log")
+	owner, err := Open(path, Options{MaxSize: 10, Keep: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer owner.Close()
+	other, err := Open(path, Options{MaxSize: 10, Keep: 1, NoRotate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	// Writes exceeding MaxSize don't rotate a NoRotate file.
+	other.Write([]byte("other one\n"))
+	other.Write([]byte("other two\n"))
+	if err := other.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("unexpected generation 1: %v", err)
+	}
+
+	// Writes by other processes are accounted for, and their rotations followed.
+	owner.Write([]byte("owner\n"))
+	other.Write([]byte("other three\n"))
+	if got := readFile(t, path+".1"); got != "other one\nother two\n" {
+		t.Errorf("generation 1: got %q", got)
+	}
+	if got := readFile(t, path); got != "owner\nother three\n" {
+		t.Errorf("current generation: got %q", got)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
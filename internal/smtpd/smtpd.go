@@ -0,0 +1,176 @@
+// Package smtpd implements a minimal SMTP server (RFC 5321) for message
+// submission from trusted local applications.
+//
+// There is no support for authentication or TLS.
+package smtpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxSize = 10 << 20 // Default maximum message size (10 MiB).
+	maxRecipients  = 100
+	idleTimeout    = 5 * time.Minute
+)
+
+// Handler is called for each received message with the envelope sender and recipients and
+// the message data (with LF line endings). Returning an error rejects the message, and the error text is
+// returned to the client.
+type Handler func(from string, to []string, data []byte) error
+
+// Server is an SMTP server.
+type Server struct {
+	Addr     string  // TCP address to listen on.
+	Hostname string  // Hostname used in greetings. Defaults to localhost.
+	Handler  Handler // Handler for received messages.
+	MaxSize  int     // Maximum message size in bytes. Defaults to DefaultMaxSize.
+}
+
+// ListenAndServe listens on the server address and serves connections until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	go func() { <-ctx.Done(); ln.Close() }()
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on the listener until it is closed.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	hostname, maxSize := s.Hostname, s.MaxSize
+	if hostname == "" {
+		hostname = "localhost"
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	tc := textproto.NewConn(conn)
+	reply := func(code int, format string, args ...any) {
+		msg := strings.NewReplacer("\r", " ", "\n", " ").Replace(fmt.Sprintf(format, args...))
+		tc.PrintfLine("%d %s", code, msg)
+	}
+	var from string
+	var to []string
+	var hasFrom bool
+	reset := func() { from, to, hasFrom = "", nil, false }
+
+	reply(220, "%s ESMTP Pat", hostname)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reset()
+			reply(250, "%s", hostname)
+		case "EHLO":
+			reset()
+			tc.PrintfLine("250-%s", hostname)
+			tc.PrintfLine("250-SIZE %d", maxSize)
+			tc.PrintfLine("250 8BITMIME")
+		case "MAIL":
+			addr, ok := parsePath(arg, "FROM:")
+			switch {
+			case !ok:
+				reply(501, "Syntax: MAIL FROM:<address>")
+			case hasFrom:
+				reply(503, "Sender already specified")
+			default:
+				from, to, hasFrom = addr, nil, true
+				reply(250, "OK")
+			}
+		case "RCPT":
+			addr, ok := parsePath(arg, "TO:")
+			switch {
+			case !hasFrom:
+				reply(503, "Need MAIL before RCPT")
+			case !ok || addr == "":
+				reply(501, "Syntax: RCPT TO:<address>")
+			case len(to) >= maxRecipients:
+				reply(452, "Too many recipients")
+			default:
+				to = append(to, addr)
+				reply(250, "OK")
+			}
+		case "DATA":
+			if len(to) == 0 {
+				reply(503, "Need RCPT before DATA")
+				continue
+			}
+			reply(354, "End data with <CR><LF>.<CR><LF>")
+			dr := tc.DotReader()
+			data, err := io.ReadAll(io.LimitReader(dr, int64(maxSize)+1))
+			if err == nil {
+				_, err = io.Copy(io.Discard, dr) // Skip the rest of an oversized message.
+			}
+			if err != nil {
+				return
+			}
+			switch {
+			case len(data) > maxSize:
+				reply(552, "Message exceeds maximum size")
+			case s.Handler == nil:
+				reply(554, "Transaction failed")
+			default:
+				if err := s.Handler(from, to, data); err != nil {
+					reply(554, "Transaction failed: %v", err)
+				} else {
+					reply(250, "OK: queued")
+				}
+			}
+			reset()
+		case "RSET":
+			reset()
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "VRFY":
+			reply(252, "Cannot VRFY user")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		case "STARTTLS", "AUTH":
+			reply(502, "Command not implemented")
+		default:
+			reply(500, "Command not recognized")
+		}
+	}
+}
+
+// parsePath returns the address of a MAIL or RCPT argument (e.g. "FROM:<foo@example.com> SIZE=123").
+func parsePath(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path, _, _ := strings.Cut(strings.TrimSpace(arg[len(prefix):]), " ") // Ignore ESMTP parameters.
+	if len(path) < 2 || path[0] != '<' || path[len(path)-1] != '>' {
+		return "", false
+	}
+	return path[1 : len(path)-1], true
+}
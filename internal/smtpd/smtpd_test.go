@@ -0,0 +1,67 @@
+package smtpd
+
+import (
+	"errors"
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	type delivery struct {
+		from string
+		to   []string
+		data string
+	}
+	received := make(chan delivery, 1)
+	s := &Server{MaxSize: 1024, Handler: func(from string, to []string, data []byte) error {
+		if strings.Contains(string(data), "reject") {
+			return errors.New("rejected\nby handler")
+		}
+		received <- delivery{from, to, string(data)}
+		return nil
+	}}
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go s.Serve(ln)
+
+	msg := "Subject: Test\r\n\r\n.leading dot\r\nBody\r\n"
+	if err := smtp.SendMail(ln.Addr().String(), nil, "ics@localhost", []string{"N0CALL@winlink.org", "LA5NTA@winlink.org"}, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	d := <-received
+	if d.from != "ics@localhost" || len(d.to) != 2 || d.to[1] != "LA5NTA@winlink.org" || d.data != strings.ReplaceAll(msg, "\r\n", "\n") {
+		t.Errorf("unexpected delivery: %+v", d)
+	}
+
+	err = smtp.SendMail(ln.Addr().String(), nil, "ics@localhost", []string{"N0CALL@winlink.org"}, []byte("Subject: reject\r\n\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "Transaction failed: rejected by handler") {
+		t.Errorf("expected handler rejection, got %v", err)
+	}
+	err = smtp.SendMail(ln.Addr().String(), nil, "ics@localhost", []string{"N0CALL@winlink.org"}, []byte(strings.Repeat("x", 2048)))
+	if err == nil || !strings.HasPrefix(err.Error(), "552") {
+		t.Errorf("expected size rejection, got %v", err)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := map[string]string{
+		"FROM:<ics@localhost>":           "ics@localhost",
+		"from: <ics@localhost> SIZE=100": "ics@localhost",
+		"FROM:<>":                        "",
+	}
+	for arg, expect := range tests {
+		if got, ok := parsePath(arg, "FROM:"); !ok || got != expect {
+			t.Errorf("%q: expected %q, got %q (%t)", arg, expect, got, ok)
+		}
+	}
+	for _, arg := range []string{"FROM:ics@localhost", "TO:<ics@localhost>", ""} {
+		if _, ok := parsePath(arg, "FROM:"); ok {
+			t.Errorf("%q: expected syntax error", arg)
+		}
+	}
+}
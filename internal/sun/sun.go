@@ -0,0 +1,52 @@
+// Package sun calculates the time of sunrise and sunset.
+//
+// The calculation is based on the sunrise equation as described at
+// https://en.wikipedia.org/wiki/Sunrise_equation, which is accurate to within
+// a minute or two for non-polar latitudes.
+package sun
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	julianUnixEpoch = 2440587.5 // Julian date of the unix epoch.
+	julian2000      = 2451545.0 // Julian date of 2000-01-01 12:00 UTC.
+)
+
+// Events returns the time of sunrise and sunset on the given (UTC) date at the given position.
+//
+// ok is false if the sun does not rise or set on the given date (polar day or night).
+func Events(date time.Time, lat, lon float64) (rise, set time.Time, ok bool) {
+	date = date.UTC()
+	midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	n := math.Ceil(julianDate(midnight) - julian2000 + 0.0008) // Current julian day
+	meanSolarTime := n - lon/360
+	anomaly := math.Mod(357.5291+0.98560028*meanSolarTime, 360)
+	center := 1.9148*sin(anomaly) + 0.0200*sin(2*anomaly) + 0.0003*sin(3*anomaly)
+	eclipticLon := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := julian2000 + meanSolarTime + 0.0053*sin(anomaly) - 0.0069*sin(2*eclipticLon)
+
+	sinDecl := sin(eclipticLon) * sin(23.4397)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	cosHourAngle := (sin(-0.833) - sin(lat)*sinDecl) / (cos(lat) * cosDecl)
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := math.Acos(cosHourAngle) * 180 / math.Pi
+
+	return fromJulian(transit - hourAngle/360), fromJulian(transit + hourAngle/360), true
+}
+
+func julianDate(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+func fromJulian(jd float64) time.Time {
+	return time.Unix(int64(math.Round((jd-julianUnixEpoch)*86400)), 0).UTC()
+}
+
+func sin(deg float64) float64 { return math.Sin(deg * math.Pi / 180) }
+func cos(deg float64) float64 { return math.Cos(deg * math.Pi / 180) }
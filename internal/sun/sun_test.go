@@ -0,0 +1,56 @@
+package sun
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		date      time.Time
+		lat, lon  float64
+		rise, set time.Time
+		ok        bool
+	}{
+		{
+			name: "Oslo summer",
+			date: time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+			lat:  59.91, lon: 10.75,
+			rise: time.Date(2024, 6, 21, 1, 53, 0, 0, time.UTC),
+			set:  time.Date(2024, 6, 21, 20, 44, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			name: "Tokyo winter",
+			date: time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC),
+			lat:  35.68, lon: 139.69,
+			rise: time.Date(2024, 12, 20, 21, 47, 0, 0, time.UTC),
+			set:  time.Date(2024, 12, 21, 7, 32, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			name: "Svalbard midnight sun",
+			date: time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+			lat:  78.22, lon: 15.65,
+		},
+	}
+	const tolerance = 3 * time.Minute
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rise, set, ok := Events(tt.date, tt.lat, tt.lon)
+			if ok != tt.ok {
+				t.Fatalf("expected ok=%t, got %t", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if d := rise.Sub(tt.rise).Abs(); d > tolerance {
+				t.Errorf("sunrise: expected %s, got %s", tt.rise, rise)
+			}
+			if d := set.Sub(tt.set).Abs(); d > tolerance {
+				t.Errorf("sunset: expected %s, got %s", tt.set, set)
+			}
+		})
+	}
+}
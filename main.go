@@ -147,7 +147,7 @@ func optionsSet(opts *app.Options) *pflag.FlagSet {
 	set.StringVar(&opts.FormsPath, "forms", defaultFormsPath, "Path to forms directory.")
 	set.StringVar(&opts.ConfigPath, "config", defaultConfigPath, "Path to config file.")
 	set.StringVar(&opts.PrehooksPath, "prehooks", defaultPrehooksPath, "Path to prehooks")
-	set.StringVar(&opts.LogPath, "log", defaultLogPath, "Path to log file. The file is truncated on each startup.")
+	set.StringVar(&opts.LogPath, "log", defaultLogPath, "Path to log file. A new file is started on each startup (see log_rotation in config).")
 	set.StringVar(&opts.EventLogPath, "event-log", defaultEventLogPath, "Path to event log file.")
 
 	return set